@@ -32,6 +32,7 @@ Other examples
 	Color(s, "red:white")      // red on white
 	Color(s, "red+b:white+h")  // red bold on white bright
 	Color(s, "red+B:white+h")  // red blink on white bright
+	Color(s, "#ff8800:#000000") // truecolor orange on black
 
 To view color combinations, from terminal
 
@@ -51,6 +52,8 @@ Colors
 	magenta
 	cyan
 	white
+	0...255 (256 colors)
+	#rrggbb (24-bit truecolor)
 
 Attributes
 
@@ -67,6 +70,9 @@ package ansi
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"os"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -104,6 +110,9 @@ const (
 
 var (
 	plain = false
+	// CSI sequences, i.e. SGR colors: ESC [ parameters intermediates final,
+	// and OSC sequences, i.e. Link: ESC ] ... BEL or ESC \
+	escape = regexp.MustCompile("\x1b\\[[0-?]*[ -/]*[@-~]|\x1b\\][^\x07\x1b]*(?:\x07|\x1b\\\\)")
 	// Colors maps common color names to their ANSI color code.
 	Colors = map[string]int{
 		"black":   black,
@@ -135,6 +144,37 @@ var (
 	LightWhite   = ColorCode("white+h")
 )
 
+func init() {
+	if noColor() {
+		DisableColors(true)
+	}
+}
+
+// noColor reports whether NO_COLOR is set. See https://no-color.org.
+func noColor() bool {
+	return os.Getenv("NO_COLOR") != ""
+}
+
+// ColorEnabled reports whether colors should be written to stdout: NO_COLOR
+// is not set and stdout is a terminal. Colors are disabled at startup when
+// NO_COLOR is set.
+func ColorEnabled() bool {
+	return ColorEnabledFor(os.Stdout)
+}
+
+// ColorEnabledFor reports whether colors should be written to w: NO_COLOR is
+// not set and w is a terminal. A w that is not an *os.File is a terminal.
+func ColorEnabledFor(w io.Writer) bool {
+	if noColor() {
+		return false
+	}
+	if f, ok := w.(*os.File); ok {
+		fi, err := f.Stat()
+		return err == nil && fi.Mode()&os.ModeCharDevice != 0
+	}
+	return true
+}
+
 // ColorCode returns the ANSI color color code for style.
 func ColorCode(style string) string {
 	return colorCode(style).String()
@@ -195,9 +235,11 @@ func colorCode(style string) *bytes.Buffer {
 		}
 	}
 
-	// if 256-color
-	n, err := strconv.Atoi(fgKey)
-	if err == nil {
+	// if truecolor
+	if r, g, b, ok := hexColor(fgKey); ok {
+		fmt.Fprintf(buf, "38;2;%d;%d;%d;", r, g, b)
+	} else if n, err := strconv.Atoi(fgKey); err == nil {
+		// if 256-color
 		fmt.Fprintf(buf, "38;5;%d;", n)
 	} else {
 		fmt.Fprintf(buf, "%d;", base+fg)
@@ -208,9 +250,11 @@ func colorCode(style string) *bytes.Buffer {
 		if strings.Contains(bgStyle, "h") {
 			base = highIntensityBG
 		}
-		// if 256-color
-		n, err := strconv.Atoi(bg)
-		if err == nil {
+		// if truecolor
+		if r, g, b, ok := hexColor(bg); ok {
+			fmt.Fprintf(buf, "48;2;%d;%d;%d;", r, g, b)
+		} else if n, err := strconv.Atoi(bg); err == nil {
+			// if 256-color
 			fmt.Fprintf(buf, "48;5;%d;", n)
 		} else {
 			fmt.Fprintf(buf, "%d;", base+Colors[bg])
@@ -223,6 +267,45 @@ func colorCode(style string) *bytes.Buffer {
 	return buf
 }
 
+// hexColor parses a #rrggbb truecolor.
+func hexColor(s string) (r, g, b uint8, ok bool) {
+	if len(s) != 7 || s[0] != '#' {
+		return 0, 0, 0, false
+	}
+	n, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return uint8(n >> 16), uint8(n >> 8), uint8(n), true
+}
+
+// RGB returns the ANSI 24-bit truecolor foreground code for r, g, b. Use
+// "#rrggbb" in a style for a background; i.e. "#ff8800:#000000".
+func RGB(r, g, b uint8) string {
+	if plain {
+		return ""
+	}
+	return fmt.Sprintf("%s38;2;%d;%d;%dm", start, r, g, b)
+}
+
+// Strip removes ANSI CSI sequences, including colors, and OSC sequences,
+// including links, from s.
+func Strip(s string) string {
+	if strings.IndexByte(s, '\x1b') < 0 {
+		return s
+	}
+	return escape.ReplaceAllLiteralString(s, "")
+}
+
+// Link returns text as an OSC 8 terminal hyperlink to url. Terminals without
+// OSC 8 support show text. Link returns text when colors are disabled.
+func Link(url, text string) string {
+	if plain {
+		return text
+	}
+	return "\033]8;;" + url + "\033\\" + text + "\033]8;;\033\\"
+}
+
 // Color colors a string based on the ANSI color code for style.
 func Color(s, style string) string {
 	if plain || len(style) < 1 {
@@ -3,6 +3,7 @@ package ansi_test
 import (
 	"fmt"
 	. "github.com/aletheia7/sd/v6/ansi"
+	"os"
 	"sort"
 	"strings"
 	"testing"
@@ -97,3 +98,44 @@ func padColor(color string, styles []string) string {
 	}
 	return buffer
 }
+
+func TestTruecolor(t *testing.T) {
+	DisableColors(false)
+	if code := ColorCode("#ff8800+b:#000010"); code != "\033[1;38;2;255;136;0;48;2;0;0;16m" {
+		t.Errorf("%q", code)
+	}
+	if code := RGB(255, 136, 0); code != "\033[38;2;255;136;0m" {
+		t.Errorf("%q", code)
+	}
+}
+
+func TestStrip(t *testing.T) {
+	DisableColors(false)
+	s := Color("foo", "red+b:white") + " \033[2Kbar " + Color("baz", "#ff8800")
+	if r := Strip(s); r != "foo bar baz" {
+		t.Errorf("%q", r)
+	}
+}
+
+func TestColorEnabledFor(t *testing.T) {
+	var b strings.Builder
+	os.Setenv("NO_COLOR", "1")
+	if ColorEnabledFor(&b) {
+		t.Error("NO_COLOR is set")
+	}
+	os.Unsetenv("NO_COLOR")
+	if !ColorEnabledFor(&b) {
+		t.Error("NO_COLOR is not set")
+	}
+}
+
+func TestLink(t *testing.T) {
+	DisableColors(false)
+	s := Link("https://example.com", "example")
+	if s != "\033]8;;https://example.com\033\\example\033]8;;\033\\" {
+		t.Errorf("%q", s)
+	}
+	if r := Strip(s); r != "example" {
+		t.Errorf("%q", r)
+	}
+}
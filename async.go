@@ -0,0 +1,153 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+
+package sd
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type async_entry struct {
+	j      *Journal
+	fields map[string]interface{}
+	loc    *location
+	// flushed is closed by drain for Sync
+	flushed chan struct{}
+}
+
+type async_queue struct {
+	lock   sync.RWMutex
+	queue  chan async_entry
+	done   chan struct{}
+	drop   bool
+	closed bool
+}
+
+// New_async makes a Journal that sends asynchronously. The send methods
+// queue entries in a channel of size buf and return; a goroutine sends
+// them to the writer and the journal. A full queue blocks the caller unless
+// Set_async_drop(true) is used. Send errors are not returned.
+//
+// The returned func flushes the queue and stops the goroutine; entries are
+// sent synchronously afterwards. Call it before the program exits.
+//
+func New_async(buf int, opt ...option) (*Journal, func() error) {
+	j := New_journal_m(nil)
+	a := &async_queue{
+		queue: make(chan async_entry, buf),
+		done:  make(chan struct{}),
+	}
+	j.async = a
+	j.Option(opt...)
+	go j.drain()
+	return j, a.close
+}
+
+// Set_async_drop drops entries when the New_async() queue is full instead of
+// blocking. Default: false.
+//
+func Set_async_drop(drop bool) option {
+	return func(o *Journal) option {
+		if o.async == nil {
+			return Set_async_drop(false)
+		}
+		o.async.lock.Lock()
+		defer o.async.lock.Unlock()
+		prev := o.async.drop
+		o.async.drop = drop
+		return Set_async_drop(prev)
+	}
+}
+
+func (j *Journal) drain() {
+	for e := range j.async.queue {
+		if e.flushed != nil {
+			close(e.flushed)
+			continue
+		}
+		e.j.lock.Lock()
+		e.j.count(e.j.deliver(e.fields, e.loc))
+		e.j.lock.Unlock()
+	}
+	close(j.async.done)
+}
+
+// Sync waits until the entries queued by New_async() Journals are sent.
+// Entries are sent before the send methods return otherwise. Sync then sends
+// the "message repeated" summaries of entries suppressed so far; see
+// Set_rate_limit().
+//
+func (j *Journal) Sync() error {
+	j.lock.Lock()
+	a := j.async
+	j.lock.Unlock()
+	if a != nil {
+		flushed := make(chan struct{})
+		a.lock.RLock()
+		if !a.closed {
+			a.queue <- async_entry{flushed: flushed}
+			a.lock.RUnlock()
+			<-flushed
+		} else {
+			a.lock.RUnlock()
+		}
+	}
+	j.rate_flush()
+	return nil
+}
+
+// Close sends the queued entries and stops the New_async() goroutine, like
+// the func returned by New_async(). Journals from With() share the queue.
+// Entries are sent synchronously afterwards. Close sends the rate limit
+// summaries like Sync() and stops sending to the Set_syslog_remote()
+// server. Close returns nil for other Journals.
+//
+func (j *Journal) Close() error {
+	j.lock.Lock()
+	a := j.async
+	j.lock.Unlock()
+	var err error
+	if a != nil {
+		err = a.close()
+	}
+	j.rate_flush()
+	j.lock.Lock()
+	r := j.remote
+	j.remote = nil
+	j.lock.Unlock()
+	r.release()
+	return err
+}
+
+// enqueue returns false when the queue is closed.
+//
+func (j *Journal) enqueue(e async_entry) bool {
+	a := j.async
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.closed {
+		return false
+	}
+	if !a.drop {
+		a.queue <- e
+		return true
+	}
+	select {
+	case a.queue <- e:
+	default:
+		atomic.AddUint64(&j.dropped, 1)
+	}
+	return true
+}
+
+func (a *async_queue) close() error {
+	a.lock.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.lock.Unlock()
+	<-a.done
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+
+package sd_test
+
+// Use -tags sd_nosend to run without journald:
+//
+//	go test -tags sd_nosend -run - -bench .
+
+import (
+	"fmt"
+	. "github.com/aletheia7/sd/v6"
+	"testing"
+)
+
+func bench_fields(n int) map[string]interface{} {
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		m[fmt.Sprintf("USER_FIELD_%v", i)] = fmt.Sprintf("value %v", i)
+	}
+	return m
+}
+
+func Benchmark_Send(b *testing.B) {
+	j := New_journal()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := j.Send(map[string]interface{}{Sd_message: "Send benchmark", "PRIORITY": Log_info}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_Info_disable_journal(b *testing.B) {
+	j := New_journal()
+	restore := j.Option(Set_default_disable_journal(true))
+	defer j.Option(restore)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := j.Info("Info benchmark"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_Info(b *testing.B) {
+	j := New_journal()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := j.Info("Info benchmark"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_Info_m(b *testing.B) {
+	for _, n := range []int{0, 3, 10} {
+		b.Run(fmt.Sprintf("fields=%v", n), func(b *testing.B) {
+			j := New_journal()
+			m := bench_fields(n)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := j.Info_m(m, "Info_m benchmark"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func Benchmark_Info_m_binary(b *testing.B) {
+	j := New_journal()
+	m := bench_fields(3)
+	m["USER_BINARY"] = []byte{0x61, 0x62, 0x63, 0x00, 0x61, 0x62, 0x63}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := j.Info_m(m, "Info_m binary benchmark"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+
+package sd
+
+import (
+	"context"
+)
+
+// Set_context_fields sets fn to make fields from a context.Context; i.e.
+// sdotel.Set_otel() adds TRACE_ID and SPAN_ID. The fields are added by the
+// slog Handler and the sdotel *_ctx methods; see Context_fields(). nil
+// removes fn. Default: nil.
+//
+func Set_context_fields(fn func(ctx context.Context) map[string]interface{}) option {
+	return func(o *Journal) option {
+		prev := o.context_fields
+		o.context_fields = fn
+		return Set_context_fields(prev)
+	}
+}
+
+// Context_fields returns the Set_context_fields() fields of ctx. nil is
+// returned without a function or ctx.
+//
+func (j *Journal) Context_fields(ctx context.Context) map[string]interface{} {
+	j.lock.Lock()
+	fn := j.context_fields
+	j.lock.Unlock()
+	if fn == nil || ctx == nil {
+		return nil
+	}
+	return fn(ctx)
+}
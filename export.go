@@ -0,0 +1,43 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+
+package sd
+
+import (
+	"bufio"
+	"io"
+)
+
+// Export writes entries to w in the systemd Journal Export Format; see
+// https://systemd.io/JOURNAL_EXPORT_FORMATS. The output can be imported with
+// systemd-journal-remote. Fields are written in name order. Values with a
+// newline or NUL, and []byte values, are written in the binary form. nil
+// values are skipped. Values are converted and validated like Send; trusted
+// fields with a leading _ or __, i.e. __REALTIME_TIMESTAMP, are allowed.
+//
+func Export(w io.Writer, entries ...map[string]interface{}) error {
+	bw := bufio.NewWriter(w)
+	for _, entry := range entries {
+		fields := make(map[string]interface{}, len(entry))
+		for k, v := range entry {
+			if fv, ok := field_value(v); ok {
+				v = fv
+			}
+			fields[k] = v
+		}
+		if _, err := check_fields(fields); err != nil {
+			return err
+		}
+		var b []byte
+		for _, k := range field_names(fields) {
+			if v, ok := fields[k].([]byte); ok {
+				b = append_field(b, k, v, true)
+			} else {
+				b = append_value(b, k, fields[k])
+			}
+		}
+		bw.Write(b)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,24 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build linux
+// +build linux
+
+package sd
+
+// Socket_send is socket_send for the sd_test tests.
+var Socket_send = socket_send
+
+// Set_journal_socket closes the journald socket and sets its path. The
+// previous path is returned.
+//
+func Set_journal_socket(path string) string {
+	socket.lock.Lock()
+	defer socket.lock.Unlock()
+	if socket.conn != nil {
+		socket.conn.Close()
+		socket.conn = nil
+	}
+	prev := journal_socket
+	journal_socket = path
+	return prev
+}
@@ -1,5 +1,7 @@
 // Copyright 2016 aletheia7. All rights reserved. Use of this source code is
 // governed by a BSD-2-Clause license that can be found in the LICENSE file.
 
-// Package sd provides methods to write to the systemd-journal.
 package sd
+
+// Print_only is print_only for the sd_test tests.
+var Print_only = print_only
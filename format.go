@@ -0,0 +1,98 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+
+package sd
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format_logfmt formats fields as logfmt for Set_writer_format; i.e.
+// MESSAGE="hello world" PRIORITY=info REQUEST_ID=7. Fields are in the
+// Set_field_order() order. The MESSAGE newline is removed.
+//
+func Format_logfmt(fields map[string]interface{}) string {
+	var b strings.Builder
+	for i, k := range format_names(fields) {
+		if 0 < i {
+			b.WriteByte(' ')
+		}
+		v := field_string(fields[k])
+		if k == Sd_message {
+			v = strings.TrimSuffix(v, "\n")
+		}
+		b.WriteString(k + `=`)
+		if v == `` || strings.ContainsAny(v, " =\"\\\n\t") {
+			v = strconv.Quote(v)
+		}
+		b.WriteString(v)
+	}
+	return b.String()
+}
+
+// Format_json formats fields as a JSON object for Set_writer_format. Values
+// are strings; PRIORITY is the Priority name and []byte is the string of the
+// bytes. The MESSAGE newline is removed.
+//
+func Format_json(fields map[string]interface{}) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range format_names(fields) {
+		if 0 < i {
+			b.WriteByte(',')
+		}
+		var v string
+		if bv, ok := fields[k].([]byte); ok {
+			v = string(bv)
+		} else {
+			v = field_string(fields[k])
+		}
+		if k == Sd_message {
+			v = strings.TrimSuffix(v, "\n")
+		}
+		kb, _ := json.Marshal(k)
+		vb, _ := json.Marshal(v)
+		b.Write(kb)
+		b.WriteByte(':')
+		b.Write(vb)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// format_names returns the field names in the Set_field_order() order.
+//
+func format_names(fields map[string]interface{}) []string {
+	package_lock.Lock()
+	defer package_lock.Unlock()
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	return order_names(names)
+}
+
+// order_names sorts names with the default_field_order names first.
+// package_lock must be held.
+//
+func order_names(names []string) []string {
+	rank := func(k string) int {
+		for i, f := range default_field_order {
+			if f == k {
+				return i
+			}
+		}
+		return len(default_field_order)
+	}
+	sort.Slice(names, func(a, b int) bool {
+		ra, rb := rank(names[a]), rank(names[b])
+		if ra != rb {
+			return ra < rb
+		}
+		return names[a] < names[b]
+	})
+	return names
+}
@@ -1,3 +1,10 @@
 module github.com/aletheia7/sd/v6
 
 go 1.15
+
+require (
+	github.com/sirupsen/logrus v1.9.3
+	go.opentelemetry.io/otel/trace v1.0.0
+	go.uber.org/zap v1.21.0
+	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8
+)
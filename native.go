@@ -0,0 +1,58 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+
+package sd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+)
+
+// append_field appends k and v in the journal native protocol and export
+// format framing: KEY=value\n, or KEY\n<64-bit little endian size>value\n
+// when binary is true or v has a newline or NUL.
+//
+func append_field(b []byte, k string, v []byte, binary_value bool) []byte {
+	if !binary_value && bytes.IndexByte(v, '\n') < 0 && bytes.IndexByte(v, 0) < 0 {
+		b = append(b, k...)
+		b = append(b, '=')
+		b = append(b, v...)
+		return append(b, '\n')
+	}
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(v)))
+	b = append(b, k...)
+	b = append(b, '\n')
+	b = append(b, size[:]...)
+	b = append(b, v...)
+	return append(b, '\n')
+}
+
+// append_value appends a check_fields() field.
+//
+func append_value(b []byte, k string, v interface{}) []byte {
+	switch t := v.(type) {
+	case string:
+		return append_field(b, k, []byte(t), false)
+	case Priority:
+		return append_field(b, k, []byte(t), false)
+	case []byte:
+		return append_field(b, k, t, false)
+	}
+	return b
+}
+
+// field_names returns the names of the non-nil fields in name order, so an
+// entry is always serialized the same way.
+//
+func field_names(fields map[string]interface{}) []string {
+	names := make([]string, 0, len(fields))
+	for k, v := range fields {
+		if v != nil {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
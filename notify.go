@@ -0,0 +1,88 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build linux && cgo
+// +build linux,cgo
+
+package sd
+
+/*
+#include <stdlib.h>
+#include <systemd/sd-daemon.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Notify sends state to the service manager; see man sd_notify. state is
+// newline separated assignments, i.e. "READY=1", "STATUS=Processing",
+// "RELOADING=1", "STOPPING=1". unset_env unsets NOTIFY_SOCKET so child
+// processes cannot notify.
+//
+// Notify returns false when the process was not started by a service
+// manager with a notification socket; i.e. not a Type=notify unit.
+//
+func Notify(unset_env bool, state string) (bool, error) {
+	cs := C.CString(state)
+	defer C.free(unsafe.Pointer(cs))
+	defer unset_notify_socket(unset_env)
+	return notify_result("sd_notify", C.sd_notify(c_bool(unset_env), cs))
+}
+
+// Notify_with_fds is Notify that also sends file descriptors; see man
+// sd_pid_notify_with_fds. Use "FDSTORE=1" in state to store fds in the
+// service manager; i.e. "FDSTORE=1\nFDNAME=listener".
+//
+func Notify_with_fds(unset_env bool, state string, fds []int) (bool, error) {
+	cs := C.CString(state)
+	defer C.free(unsafe.Pointer(cs))
+	defer unset_notify_socket(unset_env)
+	var cfds *C.int
+	if 0 < len(fds) {
+		cfds = (*C.int)(C.malloc(C.size_t(C.sizeof_int * len(fds))))
+		defer C.free(unsafe.Pointer(cfds))
+		a := (*[1 << 28]C.int)(unsafe.Pointer(cfds))[:len(fds):len(fds)]
+		for i, fd := range fds {
+			a[i] = C.int(fd)
+		}
+	}
+	return notify_result("sd_pid_notify_with_fds", C.sd_pid_notify_with_fds(0, c_bool(unset_env), cs, cfds, C.uint(len(fds))))
+}
+
+// notify_result converts a sd-daemon return value. These functions return a
+// negative errno value on failure.
+//
+func notify_result(name string, n C.int) (bool, error) {
+	if n < 0 {
+		return false, fmt.Errorf("%v: %w", name, syscall.Errno(-n))
+	}
+	return 0 < n, nil
+}
+
+// unset_notify_socket unsets NOTIFY_SOCKET in the Go environment too; the C
+// unsetenv of libsystemd is not seen by os.Getenv and os/exec.
+//
+func unset_notify_socket(unset_env bool) {
+	if unset_env {
+		os.Unsetenv("NOTIFY_SOCKET")
+	}
+}
+
+func c_bool(b bool) C.int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Booted reports whether the system was booted with systemd; see man
+// sd_booted. The journal is not available when false; see
+// Set_default_disable_journal().
+//
+func Booted() (bool, error) {
+	return notify_result("sd_booted", C.sd_booted())
+}
@@ -0,0 +1,66 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build linux && !cgo
+// +build linux,!cgo
+
+package sd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// Notify sends state to the service manager like sd_notify without
+// libsystemd; see notify.go.
+//
+func Notify(unset_env bool, state string) (bool, error) {
+	return notify("sd_notify", unset_env, state, nil)
+}
+
+// Notify_with_fds is Notify that also sends file descriptors.
+//
+func Notify_with_fds(unset_env bool, state string, fds []int) (bool, error) {
+	return notify("sd_pid_notify_with_fds", unset_env, state, fds)
+}
+
+func notify(name string, unset_env bool, state string, fds []int) (bool, error) {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if unset_env {
+		os.Unsetenv("NOTIFY_SOCKET")
+	}
+	if path == `` {
+		return false, nil
+	}
+	if path[0] != '/' && path[0] != '@' {
+		return false, fmt.Errorf("%v: %w", name, syscall.EAFNOSUPPORT)
+	}
+	c, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("%v: %w", name, err)
+	}
+	defer c.Close()
+	var oob []byte
+	if 0 < len(fds) {
+		oob = syscall.UnixRights(fds...)
+	}
+	if err = send_rights(c, []byte(state), oob); err != nil {
+		return false, fmt.Errorf("%v: %w", name, err)
+	}
+	return true, nil
+}
+
+// Booted reports whether the system was booted with systemd like
+// sd_booted.
+//
+func Booted() (bool, error) {
+	fi, err := os.Lstat("/run/systemd/system/")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("sd_booted: %w", err)
+	}
+	return fi.IsDir(), nil
+}
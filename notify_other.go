@@ -0,0 +1,24 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build !linux
+// +build !linux
+
+package sd
+
+// Notify returns false; there is no service manager.
+//
+func Notify(unset_env bool, state string) (bool, error) {
+	return false, nil
+}
+
+// Notify_with_fds returns false; there is no service manager.
+//
+func Notify_with_fds(unset_env bool, state string, fds []int) (bool, error) {
+	return false, nil
+}
+
+// Booted returns false.
+//
+func Booted() (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build linux
+// +build linux
+
+package sd_test
+
+import (
+	. "github.com/aletheia7/sd/v6"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_Notify(t *testing.T) {
+	prev, had := os.LookupEnv("NOTIFY_SOCKET")
+	defer func() {
+		if had {
+			os.Setenv("NOTIFY_SOCKET", prev)
+		} else {
+			os.Unsetenv("NOTIFY_SOCKET")
+		}
+	}()
+	os.Unsetenv("NOTIFY_SOCKET")
+	if ok, err := Notify(false, "READY=1"); ok || err != nil {
+		t.Error(ok, err)
+	}
+	path := filepath.Join(t.TempDir(), "notify")
+	c, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	os.Setenv("NOTIFY_SOCKET", path)
+	if ok, err := Notify(false, "STATUS=testing"); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	b := make([]byte, 1024)
+	n, err := c.Read(b)
+	if err != nil || string(b[:n]) != "STATUS=testing" {
+		t.Errorf("%v %q", err, b[:n])
+	}
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if ok, err := Notify_with_fds(true, "FDSTORE=1\nFDNAME=null", []int{int(f.Fd())}); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	oob := make([]byte, syscall.CmsgSpace(4))
+	n, oobn, _, _, err := c.ReadMsgUnix(b, oob)
+	if err != nil || string(b[:n]) != "FDSTORE=1\nFDNAME=null" {
+		t.Errorf("%v %q", err, b[:n])
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatal(err, len(msgs))
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatal(err, fds)
+	}
+	syscall.Close(fds[0])
+	if _, ok := os.LookupEnv("NOTIFY_SOCKET"); ok {
+		t.Error("NOTIFY_SOCKET is set")
+	}
+	if ok, err := Notify(false, "READY=1"); ok || err != nil {
+		t.Error(ok, err)
+	}
+}
+
+func Test_Booted(t *testing.T) {
+	fi, err := os.Lstat("/run/systemd/system/")
+	expected := err == nil && fi.IsDir()
+	booted, err := Booted()
+	if err != nil || booted != expected {
+		t.Error(booted, err)
+	}
+}
+
+func Test_Stream_fd(t *testing.T) {
+	if _, err := Stream_fd("sd_test", Priority("x"), true); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := os.Stat("/run/systemd/journal/stdout"); err != nil {
+		t.Skip("journald is not running")
+	}
+	f, err := Stream_fd("sd_test", Log_notice, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err != nil || fi.Mode()&os.ModeSocket == 0 {
+		t.Error(fi.Mode(), err)
+	}
+	if _, err := f.WriteString("<4>Stream_fd test\n"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+
+package sd
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type rate_limit struct {
+	lock     sync.Mutex
+	interval time.Duration
+	burst    int
+	sweep    time.Time
+	seen     map[string]*rate_state
+}
+
+type rate_state struct {
+	start      time.Time
+	count      int
+	suppressed int
+	priority   Priority
+	message    string
+}
+
+// Set_rate_limit allows burst entries with an identical MESSAGE and PRIORITY
+// per interval. MESSAGE is compared after Set_message_transform and
+// Set_redactor. Further entries are suppressed until the interval expires;
+// then a "message repeated N times: [MESSAGE]" entry is sent like the other
+// entries.
+// An interval or burst < 1 disables rate limiting. Default: disabled.
+//
+func Set_rate_limit(interval time.Duration, burst int) option {
+	return func(o *Journal) option {
+		prev := o.rate
+		if 0 < interval && 0 < burst {
+			o.rate = &rate_limit{interval: interval, burst: burst, sweep: time.Now(), seen: map[string]*rate_state{}}
+		} else {
+			o.rate = nil
+		}
+		if prev == nil {
+			return Set_rate_limit(0, 0)
+		}
+		return Set_rate_limit(prev.interval, prev.burst)
+	}
+}
+
+// rate_allow reports whether fields may be sent. Summaries of suppressed
+// entries are sent for expired intervals. j.lock must be held.
+//
+func (j *Journal) rate_allow(fields map[string]interface{}) bool {
+	r := j.rate
+	if r == nil {
+		return true
+	}
+	msg, ok := fields[Sd_message].(string)
+	if !ok {
+		return true
+	}
+	p, _ := fields[sd_priority].(Priority)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	now := time.Now()
+	if r.interval <= now.Sub(r.sweep) {
+		r.sweep = now
+		for k, st := range r.seen {
+			if r.interval <= now.Sub(st.start) {
+				j.rate_summary(st)
+				delete(r.seen, k)
+			}
+		}
+	}
+	key := string(p) + "\x00" + msg
+	st := r.seen[key]
+	if st == nil || r.interval <= now.Sub(st.start) {
+		if st != nil {
+			j.rate_summary(st)
+		}
+		r.seen[key] = &rate_state{start: now, count: 1, priority: p, message: msg}
+		return true
+	}
+	st.count++
+	if st.count <= r.burst {
+		return true
+	}
+	st.suppressed++
+	return false
+}
+
+// rate_flush sends the summaries of entries suppressed in the current
+// intervals; see Sync() and Close(). The intervals continue.
+//
+func (j *Journal) rate_flush() {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	r := j.rate
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, st := range r.seen {
+		j.rate_summary(st)
+		st.suppressed = 0
+	}
+}
+
+// rate_summary sends the suppressed count of st like an entry: it is
+// filtered, see filter(), and written to the writer and the journal. The
+// message of st was filtered already. j.lock must be held.
+//
+func (j *Journal) rate_summary(st *rate_state) {
+	if st.suppressed == 0 {
+		return
+	}
+	fields := make(map[string]interface{}, len(j.default_fields))
+	for k, v := range j.default_fields {
+		switch k {
+		case sd_go_func, sd_go_file, sd_go_line, sd_code_func, sd_code_file, sd_code_line:
+			continue
+		}
+		fields[k] = v
+	}
+	fields[Sd_message] = fmt.Sprintf("message repeated %v times: [%v]", st.suppressed, strings.TrimSuffix(st.message, "\n"))
+	fields[sd_priority] = st.priority
+	j.filter(fields)
+	j.output(fields, &location{})
+}
@@ -0,0 +1,63 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+
+package sd
+
+import (
+	"github.com/aletheia7/sd/v6/ansi"
+	"io"
+	"strings"
+	"sync"
+)
+
+type ring_writer struct {
+	lock    sync.Mutex
+	lines   []string
+	next    int
+	full    bool
+	partial string
+}
+
+// New_ring_writer returns an io.Writer that keeps the last n lines written,
+// and a func that returns them, oldest first, without the newline. Use it
+// with Set_writer() to show recent entries; i.e. from an HTTP handler. A line
+// is kept when its newline is written. ANSI colors are removed. n less than
+// 1 keeps 1 line.
+//
+func New_ring_writer(n int) (io.Writer, func() []string) {
+	if n < 1 {
+		n = 1
+	}
+	w := &ring_writer{lines: make([]string, n)}
+	return w, w.get
+}
+
+func (w *ring_writer) Write(b []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	s := w.partial + string(b)
+	for {
+		i := strings.IndexByte(s, '\n')
+		if i == -1 {
+			break
+		}
+		w.lines[w.next] = ansi.Strip(s[:i])
+		w.next++
+		if w.next == len(w.lines) {
+			w.next = 0
+			w.full = true
+		}
+		s = s[i+1:]
+	}
+	w.partial = s
+	return len(b), nil
+}
+
+func (w *ring_writer) get() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if !w.full {
+		return append([]string(nil), w.lines[:w.next]...)
+	}
+	return append(append(make([]string, 0, len(w.lines)), w.lines[w.next:]...), w.lines[:w.next]...)
+}
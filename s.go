@@ -1,6 +1,5 @@
 // Copyright 2016 aletheia7. All rights reserved. Use of this source code is
 // governed by a BSD-2-Clause license that can be found in the LICENSE file.
-// +build linux
 
 // Package sd provides methods to write to the systemd-journal.
 package sd
@@ -22,57 +21,117 @@ Each method
 also contains a _a_f (array & format variation) method that supports
 http://godoc.org/fmt#Printf style arguments.
 
-Each of the methods will add journal fields GO_LINE, and GO_FUNC fields to
+Each of the methods will add journal fields GO_FILE, and GO_FUNC fields to
 the journal to indicate where the methods were called. The *_m_f methods
 can take nil map in order to only use the format functionality.
 */
 
-/*
-#cgo pkg-config: libsystemd
-#include <stdlib.h>
-#include <systemd/sd-journal.h>
-#include <unistd.h>
-*/
-import "C"
-
 import (
-	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/aletheia7/sd/v6/ansi"
 	"io"
-	"log/syslog"
+	"io/ioutil"
+	"log"
 	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
-	"unsafe"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unicode"
 )
 
 type Priority string
 
-// These are log/syslog.Priority values.
+// These are the log/syslog.Priority severities. log/syslog is not used;
+// it does not build on windows.
 var (
-	Log_emerg   = Priority(strconv.Itoa(int(syslog.LOG_EMERG)))
-	Log_alert   = Priority(strconv.Itoa(int(syslog.LOG_ALERT)))
-	Log_crit    = Priority(strconv.Itoa(int(syslog.LOG_CRIT)))
-	Log_err     = Priority(strconv.Itoa(int(syslog.LOG_ERR)))
-	Log_warning = Priority(strconv.Itoa(int(syslog.LOG_WARNING)))
-	Log_notice  = Priority(strconv.Itoa(int(syslog.LOG_NOTICE)))
-	Log_info    = Priority(strconv.Itoa(int(syslog.LOG_INFO)))
-	Log_debug   = Priority(strconv.Itoa(int(syslog.LOG_DEBUG)))
+	Log_emerg   = Priority("0") // syslog.LOG_EMERG
+	Log_alert   = Priority("1") // syslog.LOG_ALERT
+	Log_crit    = Priority("2") // syslog.LOG_CRIT
+	Log_err     = Priority("3") // syslog.LOG_ERR
+	Log_warning = Priority("4") // syslog.LOG_WARNING
+	Log_notice  = Priority("5") // syslog.LOG_NOTICE
+	Log_info    = Priority("6") // syslog.LOG_INFO
+	Log_debug   = Priority("7") // syslog.LOG_DEBUG
 )
 
+var priority_names = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// Name returns the syslog keyword for p; i.e. "err" for Log_err. An unknown
+// Priority returns "".
+//
+func (p Priority) Name() string {
+	if n, err := strconv.Atoi(string(p)); err == nil && 0 <= n && n < len(priority_names) {
+		return priority_names[n]
+	}
+	return ``
+}
+
+// parse_priority returns the Priority of a syslog keyword or number; i.e.
+// "err" or "3". ok is false otherwise.
+//
+func parse_priority(s string) (p Priority, ok bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for i, name := range priority_names {
+		if s == name || s == strconv.Itoa(i) {
+			return Priority(strconv.Itoa(i)), true
+		}
+	}
+	return ``, false
+}
+
+// level returns the value of p; 8 for an unknown Priority.
+//
+func (p Priority) level() int {
+	if n, err := strconv.Atoi(string(p)); err == nil && 0 <= n && n < len(priority_names) {
+		return n
+	}
+	return len(priority_names)
+}
+
+// String returns the keyword and value of p; i.e. "err(3)".
+//
+func (p Priority) String() string {
+	if name := p.Name(); name != `` {
+		return name + `(` + string(p) + `)`
+	}
+	return string(p)
+}
+
 const (
 	sd_go_func  = "GO_FUNC"
 	sd_go_file  = "GO_FILE"
+	sd_go_line  = "GO_LINE"
 	sd_priority = "PRIORITY"
+	// See Set_add_stacktrace()
+	sd_stacktrace = "STACKTRACE"
 	// UUID, See man journalctl --new-id128
 	sd_message_id = "MESSAGE_ID"
 )
 
+// See man systemd.journal-fields
+const (
+	sd_code_func = "CODE_FUNC"
+	sd_code_file = "CODE_FILE"
+	sd_code_line = "CODE_LINE"
+
+	sd_syslog_facility = "SYSLOG_FACILITY"
+	sd_syslog_pid      = "SYSLOG_PID"
+	sd_invalid_fields  = "INVALID_FIELDS"
+	sd_sampled         = "SAMPLED"
+)
+
 type remove_ansi_escape int
 
 const (
@@ -98,15 +157,21 @@ var (
 		Log_notice:  Writer_option{ansi.ColorCode("208+bh"), true}, // orange
 		Log_info:    Writer_option{``, false},
 	}
+	default_field_colors    map[string]string
+	default_field_order     = []string{Sd_message, sd_priority}
+	default_tag             string
+	error_fields            []error_field
+	program                 = program_name()
 	default_disable_journal = false
 	default_use_color       = true
 	package_lock            sync.Mutex
 	message_priority        = map[string]interface{}{Sd_message: ``, sd_priority: ``}
 	valid_field             = regexp.MustCompile(`^[^_]{1}[\p{Lu}0-9_]*$`)
-	max_fields              = uint64(C.sysconf(C._SC_IOV_MAX))
+	trusted_field           = regexp.MustCompile(`^_{0,2}[^_]{1}[\p{Lu}0-9_]*$`)
+	log_location            = regexp.MustCompile(`^([^\s:]+\.go):(\d+): `)
+	valid_message_id        = regexp.MustCompile(`^[0-9a-f]{32}$`)
 	sd_field_name_sep_s     = string(sd_field_name_sep_b)
 	sd_field_name_sep_b     = []byte{61}
-	remove_re2              = regexp.MustCompile(`\x1b[^m]*m`)
 )
 
 // See http://www.freedesktop.org/software/systemd/man/SD_JOURNAL_SUPPRESS_LOCATION.html,
@@ -120,6 +185,12 @@ const (
 // Journal can contain default systemd fields.
 // See Set_default_fields().
 type Journal struct {
+	// 64-bit atomic counters first for alignment
+	sent               uint64
+	failed             uint64
+	dropped            uint64
+	rate_limited       uint64
+	last_sent          atomic.Value // time.Time
 	default_fields     map[string]interface{}
 	lock               sync.Mutex
 	add_go_code_fields bool
@@ -127,6 +198,32 @@ type Journal struct {
 	stack_skip         int
 	remove             remove_ansi_escape
 	priority           Priority
+	fallback           io.Writer
+	rate               *rate_limit
+	async              *async_queue
+	sink               sink
+	max_field_size     int
+	code_field_names   bool
+	stacktrace         Priority
+	writer_format      func(fields map[string]interface{}) string
+	context_fields     func(ctx context.Context) map[string]interface{}
+	auto_identifier    bool
+	sanitize_fields    bool
+	skip_invalid       bool
+	code_fields_min    Priority
+	allow_trusted      bool
+	remote             *syslog_remote
+	trim_newline       bool
+	message_transform  func(string) string
+	redactor           func(key string, value []byte) []byte
+	sample             map[Priority]*sample_state
+	writer_min         Priority
+	writer_header      string
+	go_line            bool
+	code_line          bool
+	min_priority       Priority
+	split_entries      bool
+	fatal_exit_code    int
 }
 
 type option func(o *Journal) option
@@ -156,9 +253,20 @@ func Set_default_remove_ansi(rm remove_ansi_escape) option {
 
 // Sets the journal field name to value. The field will
 // be removed when value is nil. An invalid name will be
-// silently ignored. See info for Sd_tag.
+// silently ignored. See info for Sd_tag. value is converted like Send(); a
+// value of a type Send() does not accept is ignored and the field is not
+// changed. Use Set_field_checked() for an error instead.
 //
 func Set_field(name string, value interface{}) option {
+	if value != nil {
+		fv, ok := field_value(value)
+		if !ok {
+			return func(o *Journal) option {
+				return Set_field(``, nil)
+			}
+		}
+		value = fv
+	}
 	if valid_field.FindString(name) == "" {
 		return func(o *Journal) option {
 			return Set_field(``, nil)
@@ -179,6 +287,109 @@ func Set_field(name string, value interface{}) option {
 	}
 }
 
+// Set_field_checked is Set_field that returns an error for an invalid name
+// or a value of a type Send() does not accept.
+//
+func Set_field_checked(name string, value interface{}) (option, error) {
+	if valid_field.FindString(name) == "" {
+		return nil, fmt.Errorf("Set_field: invalid field name: %q", name)
+	}
+	if value != nil {
+		if _, ok := field_value(value); !ok {
+			return nil, fmt.Errorf("Set_field %v: unsupported value type: %T", name, value)
+		}
+	}
+	return Set_field(name, value), nil
+}
+
+// Set_field_int is Set_field with the decimal v.
+//
+func Set_field_int(name string, v int) option {
+	return Set_field(name, strconv.Itoa(v))
+}
+
+// Set_field_bytes is Set_field with a copy of v. nil removes the field.
+//
+func Set_field_bytes(name string, v []byte) option {
+	if v == nil {
+		return Set_field(name, nil)
+	}
+	return Set_field(name, append([]byte{}, v...))
+}
+
+// Set_field_time is Set_field with v as the microseconds since the Unix
+// epoch, like the journal timestamps; i.e. _SOURCE_REALTIME_TIMESTAMP.
+//
+func Set_field_time(name string, v time.Time) option {
+	return Set_field(name, strconv.FormatInt(v.UnixNano()/int64(time.Microsecond), 10))
+}
+
+// Set_fields is Set_field for each of fields. The returned option restores
+// all of the previous values.
+//
+func Set_fields(fields map[string]interface{}) option {
+	opts := make([]option, 0, len(fields))
+	for k, v := range fields {
+		opts = append(opts, Set_field(k, v))
+	}
+	return func(o *Journal) option {
+		prev := make([]option, 0, len(opts))
+		for _, opt := range opts {
+			prev = append(prev, opt(o))
+		}
+		return restore(prev)
+	}
+}
+
+// restore returns an option that applies prev in reverse order.
+//
+func restore(prev []option) option {
+	return func(o *Journal) option {
+		r := make([]option, 0, len(prev))
+		for i := len(prev) - 1; 0 <= i; i-- {
+			r = append(r, prev[i](o))
+		}
+		return restore(r)
+	}
+}
+
+// Set_tag sets SYSLOG_IDENTIFIER, shown by journalctl -t. "" removes the
+// field and systemd provides the default.
+//
+func Set_tag(s string) option {
+	if s == `` {
+		return Set_field(Sd_tag, nil)
+	}
+	return Set_field(Sd_tag, s)
+}
+
+func program_name() string {
+	if len(os.Args) == 0 || os.Args[0] == `` {
+		return ``
+	}
+	return filepath.Base(os.Args[0])
+}
+
+// Set_auto_identifier sets SYSLOG_IDENTIFIER to the base name of os.Args[0]
+// when no tag is set; see Set_tag and Set_default_tag. Default: true.
+//
+func Set_auto_identifier(auto bool) option {
+	return func(o *Journal) option {
+		prev := o.auto_identifier
+		o.auto_identifier = auto
+		return Set_auto_identifier(prev)
+	}
+}
+
+// Set_default_tag sets the SYSLOG_IDENTIFIER of Journals made afterwards
+// without a SYSLOG_IDENTIFIER default field. "" disables. Default: "".
+//
+func Set_default_tag(s string) {
+	package_lock.Lock()
+	defer package_lock.Unlock()
+	default_tag = s
+}
+
 func Set_priority(p Priority) option {
 	return func(o *Journal) option {
 		prev := o.priority
@@ -195,6 +406,261 @@ func Set_writer(w io.Writer) option {
 	}
 }
 
+// Set_writer_format sets the function that formats an entry for the writer
+// instead of the colored MESSAGE; i.e. Format_logfmt or Format_json. The
+// journal still receives all fields. f must not modify fields. A newline is
+// appended when missing. nil restores the MESSAGE output. Default: nil.
+//
+func Set_writer_format(f func(fields map[string]interface{}) string) option {
+	return func(o *Journal) option {
+		prev := o.writer_format
+		o.writer_format = f
+		return Set_writer_format(prev)
+	}
+}
+
+// Set_writer_header starts each writer line with the time in layout and
+// the Priority name; i.e. Set_writer_header(time.RFC3339) writes
+// "2006-01-02T15:04:05Z07:00 warning message". "" disables. The writer
+// lines always end with a newline. Default: "".
+//
+func Set_writer_header(layout string) option {
+	return func(o *Journal) option {
+		prev := o.writer_header
+		o.writer_header = layout
+		return Set_writer_header(prev)
+	}
+}
+
+// Set_writer_min writes only entries with Priority min or more severe to the
+// writer; i.e. Set_writer_min(Log_warning) writes Warning, Err, etc. to a
+// terminal while all entries are sent to the journal. "" writes all
+// entries. Default: "".
+//
+func Set_writer_min(min Priority) option {
+	return func(o *Journal) option {
+		prev := o.writer_min
+		o.writer_min = min
+		return Set_writer_min(prev)
+	}
+}
+
+// Set_fallback_writer sets a writer for MESSAGE when sd_journal_sendv fails;
+// i.e. the journal socket does not exist in a container. Send returns the
+// sd_journal_sendv error only when the write to w fails. nil disables the
+// fallback.
+//
+func Set_fallback_writer(w io.Writer) option {
+	return func(o *Journal) option {
+		prev := o.fallback
+		o.fallback = w
+		return Set_fallback_writer(prev)
+	}
+}
+
+// Set_stack_skip sets the runtime.Callers skip for GO_FILE and GO_FUNC. Add 1
+// for each function that wraps the Journal methods so the location is the
+// caller of the wrapper. Default: 4.
+//
+func Set_stack_skip(skip int) option {
+	return func(o *Journal) option {
+		prev := o.stack_skip
+		o.stack_skip = skip
+		return Set_stack_skip(prev)
+	}
+}
+
+// Set_code_field_names sends the location fields with the systemd names,
+// CODE_FILE, CODE_LINE, and CODE_FUNC, instead of GO_FILE (<file>:<line>)
+// and GO_FUNC. journalctl and other tools recognize the systemd names.
+// Default: false.
+//
+func Set_code_field_names(standard bool) option {
+	return func(o *Journal) option {
+		prev := o.code_field_names
+		o.code_field_names = standard
+		return Set_code_field_names(prev)
+	}
+}
+
+// Set_go_field_style chooses how the line number is sent with the Go
+// location fields. combined true sends GO_FILE=<file>:<line>; false sends
+// GO_FILE=<file> and GO_LINE=<line>. Set_code_field_names takes precedence.
+// Default: true.
+//
+func Set_go_field_style(combined bool) option {
+	return func(o *Journal) option {
+		prev := !o.go_line
+		o.go_line = !combined
+		return Set_go_field_style(prev)
+	}
+}
+
+// Set_add_code_line also sends the line number as CODE_LINE with the GO_FILE
+// and GO_FUNC fields. The value is the bare decimal line number, the same as
+// with Set_code_field_names. Default: false.
+//
+func Set_add_code_line(add bool) option {
+	return func(o *Journal) option {
+		prev := o.code_line
+		o.code_line = add
+		return Set_add_code_line(prev)
+	}
+}
+
+// Set_add_stacktrace adds a STACKTRACE field with the call stack to entries
+// with Priority min or more severe; i.e. Set_add_stacktrace(Log_err) adds
+// the stack to Err, Crit, Alert and Emerg entries. The stack starts at the
+// GO_FILE location. "" disables. Default: "".
+//
+func Set_add_stacktrace(min Priority) option {
+	return func(o *Journal) option {
+		prev := o.stacktrace
+		o.stacktrace = min
+		return Set_add_stacktrace(prev)
+	}
+}
+
+// Set_message_transform sets a function applied to MESSAGE before it is sent
+// to the writer and the journal; i.e. to redact secrets or truncate. f is
+// called with the Journal locked and must not call the Journal. nil
+// disables. Default: nil.
+//
+func Set_message_transform(f func(string) string) option {
+	return func(o *Journal) option {
+		prev := o.message_transform
+		o.message_transform = f
+		return Set_message_transform(prev)
+	}
+}
+
+// Set_redactor sets a function called with each string and []byte field
+// before the entry is sent to the writer and the journal. The returned
+// value replaces the field; string fields stay strings. The location fields
+// are not redacted. f is called with the Journal locked and must not call
+// the Journal. nil disables. Default: nil.
+//
+func Set_redactor(f func(key string, value []byte) []byte) option {
+	return func(o *Journal) option {
+		prev := o.redactor
+		o.redactor = f
+		return Set_redactor(prev)
+	}
+}
+
+type sample_state struct {
+	count uint64
+	n     uint64
+}
+
+// Set_sample sends 1 of n entries with Priority p, starting with the first.
+// Sent entries have a SAMPLED=n field. The other entries are discarded and
+// the send methods return nil. n < 2 disables sampling of p. Default: no
+// sampling.
+//
+func Set_sample(p Priority, n int) option {
+	return func(o *Journal) option {
+		prev := 0
+		if st := o.sample[p]; st != nil {
+			prev = int(st.n)
+		}
+		if n < 2 {
+			delete(o.sample, p)
+		} else {
+			if o.sample == nil {
+				o.sample = map[Priority]*sample_state{}
+			}
+			o.sample[p] = &sample_state{n: uint64(n)}
+		}
+		return Set_sample(p, prev)
+	}
+}
+
+// sampled reports whether fields are sent and adds SAMPLED. j.lock must be
+// held.
+//
+func (j *Journal) sampled(fields map[string]interface{}) bool {
+	if len(j.sample) == 0 {
+		return true
+	}
+	p, _ := fields[sd_priority].(Priority)
+	st := j.sample[p]
+	if st == nil {
+		return true
+	}
+	if (atomic.AddUint64(&st.count, 1)-1)%st.n != 0 {
+		return false
+	}
+	fields[sd_sampled] = strconv.FormatUint(st.n, 10)
+	return true
+}
+
+// Set_trim_message_newline removes a trailing newline from MESSAGE before it
+// is sent to the journal. The fmt.Sprintln methods, Info(), Err(), etc., add
+// the newline. The writer output keeps the newline. Default: true.
+//
+func Set_trim_message_newline(trim bool) option {
+	return func(o *Journal) option {
+		prev := o.trim_newline
+		o.trim_newline = trim
+		return Set_trim_message_newline(prev)
+	}
+}
+
+// Set_sanitize_fields renames invalid field names with Field_name() instead
+// of failing the send; i.e. request-id is sent as REQUEST_ID. A renamed field
+// does not replace a valid field of the same name. Default: false.
+//
+func Set_sanitize_fields(sanitize bool) option {
+	return func(o *Journal) option {
+		prev := o.sanitize_fields
+		o.sanitize_fields = sanitize
+		return Set_sanitize_fields(prev)
+	}
+}
+
+// Set_skip_invalid_fields removes fields with an invalid name or an
+// unsupported value instead of failing the send. The removed names are sent
+// in INVALID_FIELDS, separated by ",". Default: false.
+//
+func Set_skip_invalid_fields(skip bool) option {
+	return func(o *Journal) option {
+		prev := o.skip_invalid
+		o.skip_invalid = skip
+		return Set_skip_invalid_fields(prev)
+	}
+}
+
+// Set_allow_trusted_fields sends field names with a leading _ or __; i.e.
+// _SOURCE_REALTIME_TIMESTAMP, for relays. journald ignores trusted fields
+// from clients and sets them itself; systemd-journal-remote and Export()
+// keep them. OBJECT_PID=<pid> needs no option; journald adds the OBJECT_*
+// fields of pid when the sender runs as root. Default: false.
+//
+func Set_allow_trusted_fields(allow bool) option {
+	return func(o *Journal) option {
+		prev := o.allow_trusted
+		o.allow_trusted = allow
+		return Set_allow_trusted_fields(prev)
+	}
+}
+
+// Default_max_field_size is systemd's DATA_SIZE_MAX. journald drops larger
+// fields.
+const Default_max_field_size = 768 * 1024 * 1024
+
+// Set_max_field_size sets the maximum size of a FIELD=value. Send returns an
+// error naming the field when a field is larger. n < 1 disables the check.
+// Default: Default_max_field_size.
+//
+func Set_max_field_size(n int) option {
+	return func(o *Journal) option {
+		prev := o.max_field_size
+		o.max_field_size = n
+		return Set_max_field_size(prev)
+	}
+}
+
 // New makes a Journal
 //
 func New(opt ...option) *Journal {
@@ -203,6 +669,19 @@ func New(opt ...option) *Journal {
 	return r
 }
 
+// Default makes a Journal with common settings: SYSLOG_IDENTIFIER is the
+// program name, see Set_auto_identifier(), ANSI escapes are removed from the
+// journal MESSAGE, and when stdout is a terminal, entries are also written to
+// stderr in color.
+//
+func Default() *Journal {
+	opt := []option{Set_remove_ansi(Remove_journal)}
+	if fi, err := os.Stdout.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		opt = append(opt, Set_writer(os.Stderr))
+	}
+	return New(opt...)
+}
+
 // New_journal makes a Journal.
 //
 func New_journal() *Journal {
@@ -220,22 +699,34 @@ func New_journal_m(default_fields map[string]interface{}) *Journal {
 		remove:             default_remove_ansi_escape,
 		writer:             default_writer,
 		stack_skip:         4,
+		sink:               journal_sink{},
+		max_field_size:     Default_max_field_size,
+		auto_identifier:    true,
+		trim_newline:       true,
+		fatal_exit_code:    1,
 	}
+	j.min_priority, _ = parse_priority(os.Getenv("SYSTEMD_LOG_LEVEL"))
+	tag := default_tag
 	package_lock.Unlock()
 	j.Set_default_fields(default_fields)
+	if _, ok := j.default_fields[Sd_tag]; !ok && tag != `` {
+		j.default_fields[Sd_tag] = tag
+	}
 	return j
 }
 
 // Option sets the options specified.
-// It returns an option to restore the last arg's previous value.
+// It returns an option to restore the previous values of all args, in
+// reverse order; i.e. defer j.Option(j.Option(a, b)).
 //
 func (o *Journal) Option(opt ...option) (previous option) {
 	o.lock.Lock()
 	defer o.lock.Unlock()
+	prev := make([]option, 0, len(opt))
 	for _, i := range opt {
-		previous = i(o)
+		prev = append(prev, i(o))
 	}
-	return
+	return restore(prev)
 }
 
 // Copy copies maps into a new map.
@@ -260,6 +751,10 @@ func (j *Journal) copy(maps ...map[string]interface{}) map[string]interface{} {
 					if 0 < len([]byte(t)) {
 						dest[k] = append([]byte{}, t...)
 					}
+				default:
+					if fv, ok := field_value(t); ok && fv != nil {
+						dest[k] = fv
+					}
 				}
 			}
 		}
@@ -267,26 +762,137 @@ func (j *Journal) copy(maps ...map[string]interface{}) map[string]interface{} {
 	return dest
 }
 
+// With returns a Journal with fields added to the default fields of j. The
+// Journal shares the journal sink, the New_async() queue and the rate limit
+// of j; the options of j are copied. j is not changed.
+//
+func (j *Journal) With(fields map[string]interface{}) *Journal {
+	r := j.clone()
+	r.default_fields = r.copy(r.default_fields, fields)
+	return r
+}
+
+// With_a is With for []string{"KEY=value"} fields; see the *_a methods.
+//
+func (j *Journal) With_a(fields []string) *Journal {
+	return j.With(j.a_to_map(fields))
+}
+
+// Clone returns a copy of j with the default fields and options of j. Unlike
+// With(), the rate limit and Set_sample() counts of the copy start empty and
+// []byte default fields are copied. The copy shares the journal sink and the
+// New_async() queue of j. Options set on either Journal do not change the
+// other.
+//
+func (j *Journal) Clone() *Journal {
+	r := j.clone()
+	for k, v := range r.default_fields {
+		if b, ok := v.([]byte); ok {
+			r.default_fields[k] = append([]byte{}, b...)
+		}
+	}
+	if r.rate != nil {
+		r.rate = &rate_limit{interval: r.rate.interval, burst: r.rate.burst, sweep: time.Now(), seen: map[string]*rate_state{}}
+	}
+	for k, v := range r.sample {
+		r.sample[k] = &sample_state{n: v.n}
+	}
+	return r
+}
+
+// clone returns a copy of j with its own default fields and Stats.
+//
+func (j *Journal) clone() *Journal {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	r := &Journal{
+		default_fields:     make(map[string]interface{}, len(j.default_fields)),
+		add_go_code_fields: j.add_go_code_fields,
+		writer:             j.writer,
+		stack_skip:         j.stack_skip,
+		remove:             j.remove,
+		priority:           j.priority,
+		fallback:           j.fallback,
+		rate:               j.rate,
+		async:              j.async,
+		sink:               j.sink,
+		max_field_size:     j.max_field_size,
+		code_field_names:   j.code_field_names,
+		stacktrace:         j.stacktrace,
+		writer_format:      j.writer_format,
+		context_fields:     j.context_fields,
+		auto_identifier:    j.auto_identifier,
+		sanitize_fields:    j.sanitize_fields,
+		skip_invalid:       j.skip_invalid,
+		code_fields_min:    j.code_fields_min,
+		allow_trusted:      j.allow_trusted,
+		remote:             j.remote.acquire(),
+		trim_newline:       j.trim_newline,
+		message_transform:  j.message_transform,
+		redactor:           j.redactor,
+		writer_min:         j.writer_min,
+		writer_header:      j.writer_header,
+		go_line:            j.go_line,
+		code_line:          j.code_line,
+		min_priority:       j.min_priority,
+		split_entries:      j.split_entries,
+		fatal_exit_code:    j.fatal_exit_code,
+	}
+	if j.sample != nil {
+		r.sample = make(map[Priority]*sample_state, len(j.sample))
+		for k, v := range j.sample {
+			r.sample[k] = v
+		}
+	}
+	for k, v := range j.default_fields {
+		r.default_fields[k] = v
+	}
+	return r
+}
+
 // Default fields are sent with every Send().
 // Do not include MESSAGE, or Priority, as these fields are always sent. The
 // allowable interface{} values are string and []byte. A copy of []byte is
 // made.
 //
 func (j *Journal) Set_default_fields(fields map[string]interface{}) {
-	j.default_fields = j.copy([]map[string]interface{}{fields, message_priority, id128}...)
+	m := j.copy([]map[string]interface{}{fields, message_priority, message_id()}...)
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.default_fields = m
+}
+
+// message_id returns the Set_message_id() fields. The map must not be
+// modified.
+//
+func message_id() map[string]interface{} {
+	package_lock.Lock()
+	defer package_lock.Unlock()
+	return id128
 }
 
+// load_defaults returns a copy of the default fields with message and
+// Priority. The copy is safe for Send to modify.
+//
 func (j *Journal) load_defaults(message string, Priority Priority) map[string]interface{} {
+	id := message_id()
 	j.lock.Lock()
 	defer j.lock.Unlock()
-	j.default_fields[Sd_message] = message
-	j.default_fields[sd_priority] = Priority
-	if id128 == nil {
-		delete(j.default_fields, sd_message_id)
+	r := make(map[string]interface{}, len(j.default_fields)+5)
+	for k, v := range j.default_fields {
+		r[k] = v
+	}
+	r[Sd_message] = message
+	r[sd_priority] = Priority
+	if _, ok := r[Sd_tag]; !ok && j.auto_identifier && program != `` {
+		r[Sd_tag] = program
+	}
+	if id == nil {
+		delete(r, sd_message_id)
 	} else {
-		j.default_fields[sd_message_id] = id128[sd_message_id]
+		r[sd_message_id] = id[sd_message_id]
 	}
-	return j.default_fields
+	return r
 }
 
 // Set_writer_priority set the priority for the write() receiver.
@@ -305,11 +911,114 @@ func (j *Journal) Set_writer_priority(p Priority) *Journal {
 // You might want to use Set_remove_ansi(true).
 // See http://godoc.org/log#SetOutput.
 //
-func (j *Journal) Write(b []byte) (int, error) {
-	return len(b), j.Send(j.load_defaults(string(b), j.priority))
+// Each newline terminated line of b is sent as a separate entry. The first
+// error is returned.
+//
+// A leading file:line: of a line, from the log.Lshortfile or log.Llongfile
+// flags, is removed from MESSAGE and sent as the location; see
+// Set_go_field_style().
+//
+func (j *Journal) Write(b []byte) (n int, err error) {
+	j.lock.Lock()
+	p := j.priority
+	j.lock.Unlock()
+	for _, s := range lines(b) {
+		var e error
+		if m := log_location.FindStringSubmatch(s); m != nil {
+			line, _ := strconv.Atoi(m[2])
+			e = j.send(j.load_defaults(s[len(m[0]):], p), &location{file: m[1], line: line})
+		} else {
+			e = j.Send(j.load_defaults(s, p))
+		}
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	return len(b), err
+}
+
+// lines splits b after each newline. A trailing empty line is dropped.
+//
+func lines(b []byte) []string {
+	r := strings.SplitAfter(string(b), "\n")
+	if 1 < len(r) && r[len(r)-1] == `` {
+		r = r[:len(r)-1]
+	}
+	return r
+}
+
+type level_writer struct {
+	j *Journal
+}
+
+// Level_writer returns an io.Writer for j that reads the priority from a
+// leading syslog <N> prefix of each line; i.e. "<3>disk full\n" sends
+// "disk full\n" with Log_err. The prefix is removed from MESSAGE. Writes without a prefix use
+// the Set_writer_priority() priority. Useful for a child process's stdout;
+// see man sd-daemon.
+//
+func Level_writer(j *Journal) io.Writer {
+	return &level_writer{j: j}
+}
+
+func (w *level_writer) Write(b []byte) (n int, err error) {
+	w.j.lock.Lock()
+	writer_priority := w.j.priority
+	w.j.lock.Unlock()
+	for _, s := range lines(b) {
+		p := writer_priority
+		if 3 <= len(s) && s[0] == '<' && '0' <= s[1] && s[1] <= '7' && s[2] == '>' {
+			p = Priority(s[1:2])
+			s = s[3:]
+		}
+		if e := w.j.Send(w.j.load_defaults(s, p)); e != nil && err == nil {
+			err = e
+		}
+	}
+	return len(b), err
+}
+
+type std_writer struct {
+	j *Journal
+	p Priority
+}
+
+// New_std_logger returns a *log.Logger that sends each line to j with
+// Priority p. prefix and flag are as in log.New, except the date and time
+// flags are removed; the journal has a timestamp. GO_FILE and GO_FUNC are
+// the caller of the log.Logger method.
+//
+func New_std_logger(j *Journal, p Priority, prefix string, flag int) *log.Logger {
+	return log.New(&std_writer{j: j, p: p}, prefix, flag&^(log.Ldate|log.Ltime|log.Lmicroseconds|log.LUTC))
+}
+
+func (w *std_writer) Write(b []byte) (n int, err error) {
+	// first caller outside of the log package
+	var pc uintptr
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "log.") {
+			pc = frame.PC + 1
+			break
+		}
+		if !more {
+			break
+		}
+	}
+	for _, s := range lines(b) {
+		if e := w.j.Log_pc(pc, w.p, s, nil); e != nil && err == nil {
+			err = e
+		}
+	}
+	return len(b), err
 }
 
 func (j *Journal) Emerg(a ...interface{}) error {
+	if !j.Enabled(Log_emerg) {
+		return nil
+	}
 	return j.Send(j.load_defaults(fmt.Sprintln(a...), Log_emerg))
 }
 
@@ -318,34 +1027,58 @@ func (j *Journal) Emerg(a ...interface{}) error {
 // systemd.journal-fields.
 //
 func (j *Journal) Alert(a ...interface{}) error {
+	if !j.Enabled(Log_alert) {
+		return nil
+	}
 	return j.Send(j.load_defaults(fmt.Sprintln(a...), Log_alert))
 }
 
 func (j *Journal) Crit(a ...interface{}) error {
+	if !j.Enabled(Log_crit) {
+		return nil
+	}
 	return j.Send(j.load_defaults(fmt.Sprintln(a...), Log_crit))
 }
 
 func (j *Journal) Err(a ...interface{}) error {
+	if !j.Enabled(Log_err) {
+		return nil
+	}
 	return j.Send(j.load_defaults(fmt.Sprintln(a...), Log_err))
 }
 
 func (j *Journal) Warning(a ...interface{}) error {
+	if !j.Enabled(Log_warning) {
+		return nil
+	}
 	return j.Send(j.load_defaults(fmt.Sprintln(a...), Log_warning))
 }
 
 func (j *Journal) Notice(a ...interface{}) error {
+	if !j.Enabled(Log_notice) {
+		return nil
+	}
 	return j.Send(j.load_defaults(fmt.Sprintln(a...), Log_notice))
 }
 
 func (j *Journal) Info(a ...interface{}) error {
+	if !j.Enabled(Log_info) {
+		return nil
+	}
 	return j.Send(j.load_defaults(fmt.Sprintln(a...), Log_info))
 }
 
 func (j *Journal) Debug(a ...interface{}) error {
+	if !j.Enabled(Log_debug) {
+		return nil
+	}
 	return j.Send(j.load_defaults(fmt.Sprintln(a...), Log_debug))
 }
 
 func (j *Journal) Emerg_m(fields map[string]interface{}, a ...interface{}) error {
+	if !j.Enabled(Log_emerg) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(fmt.Sprintln(a...), Log_emerg)}...))
 }
 
@@ -355,34 +1088,58 @@ func (j *Journal) Emerg_m(fields map[string]interface{}, a ...interface{}) error
 // systemd.journal-fields.
 //
 func (j *Journal) Alert_m(fields map[string]interface{}, a ...interface{}) error {
+	if !j.Enabled(Log_alert) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(fmt.Sprintln(a...), Log_alert)}...))
 }
 
 func (j *Journal) Crit_m(fields map[string]interface{}, a ...interface{}) error {
+	if !j.Enabled(Log_crit) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(fmt.Sprintln(a...), Log_crit)}...))
 }
 
 func (j *Journal) Err_m(fields map[string]interface{}, a ...interface{}) error {
+	if !j.Enabled(Log_err) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(fmt.Sprintln(a...), Log_err)}...))
 }
 
 func (j *Journal) Warning_m(fields map[string]interface{}, a ...interface{}) error {
+	if !j.Enabled(Log_warning) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(fmt.Sprintln(a...), Log_warning)}...))
 }
 
 func (j *Journal) Notice_m(fields map[string]interface{}, a ...interface{}) error {
+	if !j.Enabled(Log_notice) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(fmt.Sprintln(a...), Log_notice)}...))
 }
 
 func (j *Journal) Info_m(fields map[string]interface{}, a ...interface{}) error {
+	if !j.Enabled(Log_info) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(fmt.Sprintln(a...), Log_info)}...))
 }
 
 func (j *Journal) Debug_m(fields map[string]interface{}, a ...interface{}) error {
+	if !j.Enabled(Log_debug) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(fmt.Sprintln(a...), Log_debug)}...))
 }
 
 func (j *Journal) Emerg_m_f(fields map[string]interface{}, format string, a ...interface{}) error {
+	if !j.Enabled(Log_emerg) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(fmt.Sprintf(format, a...), Log_emerg)}...))
 }
 
@@ -392,30 +1149,51 @@ func (j *Journal) Emerg_m_f(fields map[string]interface{}, format string, a ...i
 // see fmt.Printf.
 //
 func (j *Journal) Alert_m_f(fields map[string]interface{}, format string, a ...interface{}) error {
+	if !j.Enabled(Log_alert) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(fmt.Sprintf(format, a...), Log_alert)}...))
 }
 
 func (j *Journal) Crit_m_f(fields map[string]interface{}, format string, a ...interface{}) error {
+	if !j.Enabled(Log_crit) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(fmt.Sprintf(format, a...), Log_crit)}...))
 }
 
 func (j *Journal) Err_m_f(fields map[string]interface{}, format string, a ...interface{}) error {
+	if !j.Enabled(Log_err) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(fmt.Sprintf(format, a...), Log_err)}...))
 }
 
 func (j *Journal) Warning_m_f(fields map[string]interface{}, format string, a ...interface{}) error {
+	if !j.Enabled(Log_warning) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(fmt.Sprintf(format, a...), Log_warning)}...))
 }
 
 func (j *Journal) Notice_m_f(fields map[string]interface{}, format string, a ...interface{}) error {
+	if !j.Enabled(Log_notice) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(fmt.Sprintf(format, a...), Log_notice)}...))
 }
 
 func (j *Journal) Info_m_f(fields map[string]interface{}, format string, a ...interface{}) error {
+	if !j.Enabled(Log_info) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(fmt.Sprintf(format, a...), Log_info)}...))
 }
 
 func (j *Journal) Debug_m_f(fields map[string]interface{}, format string, a ...interface{}) error {
+	if !j.Enabled(Log_debug) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(fmt.Sprintf(format, a...), Log_debug)}...))
 }
 
@@ -424,33 +1202,317 @@ func (j *Journal) Debug_m_f(fields map[string]interface{}, format string, a ...i
 // ...interface{}: see fmt.Printf.
 //
 func (j *Journal) Alertf(format string, a ...interface{}) error {
+	if !j.Enabled(Log_alert) {
+		return nil
+	}
 	return j.Send(j.load_defaults(fmt.Sprintf(format, a...), Log_alert))
 }
 
 func (j *Journal) Critf(format string, a ...interface{}) error {
+	if !j.Enabled(Log_crit) {
+		return nil
+	}
 	return j.Send(j.load_defaults(fmt.Sprintf(format, a...), Log_crit))
 }
 
 func (j *Journal) Errf(format string, a ...interface{}) error {
+	if !j.Enabled(Log_err) {
+		return nil
+	}
 	return j.Send(j.load_defaults(fmt.Sprintf(format, a...), Log_err))
 }
 
 func (j *Journal) Warningf(format string, a ...interface{}) error {
+	if !j.Enabled(Log_warning) {
+		return nil
+	}
 	return j.Send(j.load_defaults(fmt.Sprintf(format, a...), Log_warning))
 }
 
 func (j *Journal) Noticef(format string, a ...interface{}) error {
+	if !j.Enabled(Log_notice) {
+		return nil
+	}
 	return j.Send(j.load_defaults(fmt.Sprintf(format, a...), Log_notice))
 }
 
 func (j *Journal) Infof(format string, a ...interface{}) error {
+	if !j.Enabled(Log_info) {
+		return nil
+	}
 	return j.Send(j.load_defaults(fmt.Sprintf(format, a...), Log_info))
 }
 
 func (j *Journal) Debugf(format string, a ...interface{}) error {
+	if !j.Enabled(Log_debug) {
+		return nil
+	}
 	return j.Send(j.load_defaults(fmt.Sprintf(format, a...), Log_debug))
 }
 
+// Log sends msg with Priority p and fields. msg is not formatted; a newline
+// is added for the writer like the fmt.Sprintln methods and removed from
+// MESSAGE; see Set_trim_message_newline(). fields may be nil.
+//
+func (j *Journal) Log(p Priority, msg string, fields map[string]interface{}) error {
+	if !j.Enabled(p) {
+		return nil
+	}
+	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(msg+"\n", p)}...))
+}
+
+const (
+	Sd_errno       = "ERRNO"
+	Sd_error_chain = "ERROR_CHAIN"
+)
+
+// Err_err sends err with Log_err Priority and fields. MESSAGE is
+// err.Error(). ERRNO is added when errors.As finds a syscall.Errno in err.
+// ERROR_CHAIN has the Error() of err and each error it wraps, one per line;
+// see errors.Unwrap. A nil err sends nothing. fields may be nil.
+//
+func (j *Journal) Err_err(err error, fields map[string]interface{}) error {
+	if err == nil || !j.Enabled(Log_err) {
+		return nil
+	}
+	r := j.copy([]map[string]interface{}{fields, j.load_defaults(err.Error()+"\n", Log_err)}...)
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		r[Sd_errno] = strconv.Itoa(int(errno))
+	}
+	package_lock.Lock()
+	for _, f := range error_fields {
+		if errors.Is(err, f.target) {
+			r[f.field] = f.value
+		}
+	}
+	package_lock.Unlock()
+	var chain []string
+	error_chain(err, &chain)
+	r[Sd_error_chain] = strings.Join(chain, "\n")
+	return j.Send(r)
+}
+
+// Set_fatal_exit_code sets the os.Exit code of Fatal() and Fatalf().
+// Default: 1.
+//
+func Set_fatal_exit_code(n int) option {
+	return func(o *Journal) option {
+		prev := o.fatal_exit_code
+		o.fatal_exit_code = n
+		return Set_fatal_exit_code(prev)
+	}
+}
+
+// Fatal sends a message with Log_crit Priority like Crit(), waits for queued
+// entries, see Sync(), and calls os.Exit; see Set_fatal_exit_code().
+//
+func (j *Journal) Fatal(a ...interface{}) {
+	j.Send(j.load_defaults(fmt.Sprintln(a...), Log_crit))
+	j.exit()
+}
+
+// Fatalf is Fatal with fmt.Printf style arguments.
+//
+func (j *Journal) Fatalf(format string, a ...interface{}) {
+	j.Send(j.load_defaults(fmt.Sprintf(format, a...), Log_crit))
+	j.exit()
+}
+
+// Panic sends a message with Log_crit Priority like Crit(), waits for queued
+// entries, see Sync(), and panics with the message, like log.Panic.
+//
+func (j *Journal) Panic(a ...interface{}) {
+	s := fmt.Sprintln(a...)
+	j.Send(j.load_defaults(s, Log_crit))
+	j.Sync()
+	panic(s)
+}
+
+// Panicf is Panic with fmt.Printf style arguments.
+//
+func (j *Journal) Panicf(format string, a ...interface{}) {
+	s := fmt.Sprintf(format, a...)
+	j.Send(j.load_defaults(s, Log_crit))
+	j.Sync()
+	panic(s)
+}
+
+func (j *Journal) exit() {
+	j.Sync()
+	j.lock.Lock()
+	code := j.fatal_exit_code
+	j.lock.Unlock()
+	os.Exit(code)
+}
+
+type error_field struct {
+	target       error
+	field, value string
+}
+
+// Register_error_field adds field=value to Err_err entries when errors.Is(err,
+// target); i.e. Register_error_field(context.DeadlineExceeded, "TIMEOUT",
+// "1"). Registering target and field again replaces value.
+//
+func Register_error_field(target error, field, value string) {
+	package_lock.Lock()
+	defer package_lock.Unlock()
+	comparable := target != nil && reflect.TypeOf(target).Comparable()
+	for i, f := range error_fields {
+		if comparable && f.target == target && f.field == field {
+			error_fields[i].value = value
+			return
+		}
+	}
+	error_fields = append(error_fields, error_field{target, field, value})
+}
+
+// error_chain appends the Error() of err and the errors it wraps to chain.
+// Errors that wrap more than one error, Unwrap() []error, are walked depth
+// first.
+//
+func error_chain(err error, chain *[]string) {
+	for err != nil {
+		*chain = append(*chain, err.Error())
+		if m, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range m.Unwrap() {
+				error_chain(e, chain)
+			}
+			return
+		}
+		err = errors.Unwrap(err)
+	}
+}
+
+// Emerg_fn sends the message returned by fn with Log_emerg Priority. fn is
+// only called when the Priority is enabled; see Set_min_priority(). Like
+// Log(), a newline is added for the writer. The other *_fn methods are the
+// same for their Priority; i.e. Debug_fn(func() string { return dump(v) }).
+//
+func (j *Journal) Emerg_fn(fn func() string) error {
+	if !j.Enabled(Log_emerg) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fn()+"\n", Log_emerg))
+}
+
+func (j *Journal) Alert_fn(fn func() string) error {
+	if !j.Enabled(Log_alert) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fn()+"\n", Log_alert))
+}
+
+func (j *Journal) Crit_fn(fn func() string) error {
+	if !j.Enabled(Log_crit) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fn()+"\n", Log_crit))
+}
+
+func (j *Journal) Err_fn(fn func() string) error {
+	if !j.Enabled(Log_err) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fn()+"\n", Log_err))
+}
+
+func (j *Journal) Warning_fn(fn func() string) error {
+	if !j.Enabled(Log_warning) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fn()+"\n", Log_warning))
+}
+
+func (j *Journal) Notice_fn(fn func() string) error {
+	if !j.Enabled(Log_notice) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fn()+"\n", Log_notice))
+}
+
+func (j *Journal) Info_fn(fn func() string) error {
+	if !j.Enabled(Log_info) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fn()+"\n", Log_info))
+}
+
+func (j *Journal) Debug_fn(fn func() string) error {
+	if !j.Enabled(Log_debug) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fn()+"\n", Log_debug))
+}
+
+// Send_stream sends an entry with Priority p and field set to the contents
+// of r. field is []byte, or a string for MESSAGE. For other fields MESSAGE is
+// "<field> (<n> bytes)". Reading stops with an error when field=value exceeds
+// Set_max_field_size(), or Default_max_field_size when the check is disabled.
+// r is not read when p is not Enabled().
+//
+func (j *Journal) Send_stream(p Priority, field string, r io.Reader) error {
+	if !j.Enabled(p) {
+		return nil
+	}
+	j.lock.Lock()
+	max := j.max_field_size
+	j.lock.Unlock()
+	if max < 1 {
+		max = Default_max_field_size
+	}
+	// 1 byte more than allowed to detect a larger value
+	b, err := ioutil.ReadAll(io.LimitReader(r, int64(max-len(field))))
+	if err != nil {
+		return err
+	}
+	if max < len(field)+1+len(b) {
+		return fmt.Errorf("field %v exceeds max field size %v", field, max)
+	}
+	if field == Sd_message {
+		return j.Send(j.copy(j.load_defaults(string(b), p)))
+	}
+	return j.Send(j.copy([]map[string]interface{}{j.load_defaults(fmt.Sprintf("%v (%v bytes)\n", field, len(b)), p), {field: b}}...))
+}
+
+// Send_priority sends a message with Priority p. Useful when the priority is
+// chosen at runtime. a ...interface{}: fmt.Println formating will become
+// MESSAGE.
+//
+func (j *Journal) Send_priority(p Priority, a ...interface{}) error {
+	if !j.Enabled(p) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fmt.Sprintln(a...), p))
+}
+
+// Send_priority_f sends a message with Priority p. The message is formed via
+// fmt.Printf style arguments.
+//
+func (j *Journal) Send_priority_f(p Priority, format string, a ...interface{}) error {
+	if !j.Enabled(p) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fmt.Sprintf(format, a...), p))
+}
+
+// Send_catalog sends a message with Priority p and MESSAGE_ID id for the
+// entry only. journalctl -x shows the catalog entry of id. id "" uses the
+// Set_message_id() MESSAGE_ID. a ...interface{}: fmt.Println formating will
+// become MESSAGE. An error is returned and nothing is sent when id is not 32
+// lowercase hex characters.
+//
+func (j *Journal) Send_catalog(id string, fields map[string]interface{}, p Priority, a ...interface{}) error {
+	if id != "" && !valid_message_id.MatchString(id) {
+		return fmt.Errorf("invalid MESSAGE_ID: %q: must be 32 lowercase hex characters", id)
+	}
+	if !j.Enabled(p) {
+		return nil
+	}
+	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(fmt.Sprintln(a...), p), {sd_message_id: id}}...))
+}
+
 func (j *Journal) a_to_map(fields []string) (ret map[string]interface{}) {
 	ret = make(map[string]interface{}, len(fields))
 	for _, s := range fields {
@@ -467,30 +1529,51 @@ func (j *Journal) a_to_map(fields []string) (ret map[string]interface{}) {
 // formating will become MESSAGE; see man systemd.journal-fields.
 //
 func (j *Journal) Alert_a(fields []string, a ...interface{}) error {
+	if !j.Enabled(Log_alert) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{j.a_to_map(fields), j.load_defaults(fmt.Sprintln(a...), Log_alert)}...))
 }
 
 func (j *Journal) Crit_a(fields []string, a ...interface{}) error {
+	if !j.Enabled(Log_crit) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{j.a_to_map(fields), j.load_defaults(fmt.Sprintln(a...), Log_crit)}...))
 }
 
 func (j *Journal) Err_a(fields []string, a ...interface{}) error {
+	if !j.Enabled(Log_err) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{j.a_to_map(fields), j.load_defaults(fmt.Sprintln(a...), Log_err)}...))
 }
 
 func (j *Journal) Warning_a(fields []string, a ...interface{}) error {
+	if !j.Enabled(Log_warning) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{j.a_to_map(fields), j.load_defaults(fmt.Sprintln(a...), Log_warning)}...))
 }
 
 func (j *Journal) Notice_a(fields []string, a ...interface{}) error {
+	if !j.Enabled(Log_notice) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{j.a_to_map(fields), j.load_defaults(fmt.Sprintln(a...), Log_notice)}...))
 }
 
 func (j *Journal) Info_a(fields []string, a ...interface{}) error {
+	if !j.Enabled(Log_info) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{j.a_to_map(fields), j.load_defaults(fmt.Sprintln(a...), Log_info)}...))
 }
 
 func (j *Journal) Debug_a(fields []string, a ...interface{}) error {
+	if !j.Enabled(Log_debug) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{j.a_to_map(fields), j.load_defaults(fmt.Sprintln(a...), Log_debug)}...))
 }
 
@@ -500,30 +1583,51 @@ func (j *Journal) Debug_a(fields []string, a ...interface{}) error {
 // see fmt.Printf.
 //
 func (j *Journal) Alert_a_f(fields []string, format string, a ...interface{}) error {
+	if !j.Enabled(Log_alert) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{j.a_to_map(fields), j.load_defaults(fmt.Sprintf(format, a...), Log_alert)}...))
 }
 
 func (j *Journal) Crit_a_f(fields []string, format string, a ...interface{}) error {
+	if !j.Enabled(Log_crit) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{j.a_to_map(fields), j.load_defaults(fmt.Sprintf(format, a...), Log_crit)}...))
 }
 
 func (j *Journal) Err_a_f(fields []string, format string, a ...interface{}) error {
+	if !j.Enabled(Log_err) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{j.a_to_map(fields), j.load_defaults(fmt.Sprintf(format, a...), Log_err)}...))
 }
 
 func (j *Journal) Warning_a_f(fields []string, format string, a ...interface{}) error {
+	if !j.Enabled(Log_warning) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{j.a_to_map(fields), j.load_defaults(fmt.Sprintf(format, a...), Log_warning)}...))
 }
 
 func (j *Journal) Notice_a_f(fields []string, format string, a ...interface{}) error {
+	if !j.Enabled(Log_notice) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{j.a_to_map(fields), j.load_defaults(fmt.Sprintf(format, a...), Log_notice)}...))
 }
 
 func (j *Journal) Info_a_f(fields []string, format string, a ...interface{}) error {
+	if !j.Enabled(Log_info) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{j.a_to_map(fields), j.load_defaults(fmt.Sprintf(format, a...), Log_info)}...))
 }
 
 func (j *Journal) Debug_a_f(fields []string, format string, a ...interface{}) error {
+	if !j.Enabled(Log_debug) {
+		return nil
+	}
 	return j.Send(j.copy([]map[string]interface{}{j.a_to_map(fields), j.load_defaults(fmt.Sprintf(format, a...), Log_debug)}...))
 }
 
@@ -537,8 +1641,110 @@ func (j *Journal) Set_add_go_code_fields(use bool) {
 	j.add_go_code_fields = use
 }
 
+// Set_add_go_code_fields_min adds the GO_FILE and GO_FUNC fields only to
+// entries with Priority min or more severe; i.e. Set_add_go_code_fields_min(
+// Log_warning) skips the runtime.Callers call for Info and Debug. "" adds the
+// fields to all entries. See Set_add_go_code_fields(). Default: "".
+//
+func Set_add_go_code_fields_min(min Priority) option {
+	return func(o *Journal) option {
+		prev := o.code_fields_min
+		o.code_fields_min = min
+		return Set_add_go_code_fields_min(prev)
+	}
+}
+
+const (
+	Sd_entry_group = "ENTRY_GROUP"
+	Sd_entry_part  = "ENTRY_PART"
+)
+
+// Set_split_entries sends an entry with more fields than the journal allows,
+// IOV_MAX, as several entries instead of returning an error. Each entry has
+// MESSAGE, PRIORITY, SYSLOG_IDENTIFIER, MESSAGE_ID and the location fields of
+// the entry, a shared random ENTRY_GROUP, ENTRY_PART numbered from 1, and
+// some of the other fields; i.e. journalctl ENTRY_GROUP=<id> shows them all.
+// Default: false.
+//
+func Set_split_entries(split bool) option {
+	return func(o *Journal) option {
+		prev := o.split_entries
+		o.split_entries = split
+		return Set_split_entries(prev)
+	}
+}
+
+// Set_min_priority drops entries less severe than min; i.e.
+// Set_min_priority(Log_info) drops Debug entries. The level methods, Info(),
+// Debug(), etc., return before the message is formatted. See Enabled(). ""
+// sends all entries. Default: the SYSTEMD_LOG_LEVEL environment variable, a
+// syslog keyword or number, i.e. "debug" or "7", when the Journal is made;
+// otherwise "". Set_min_priority overrides SYSTEMD_LOG_LEVEL.
+//
+func Set_min_priority(min Priority) option {
+	return func(o *Journal) option {
+		prev := o.min_priority
+		o.min_priority = min
+		return Set_min_priority(prev)
+	}
+}
+
+// Enabled reports whether entries with Priority p are sent; see
+// Set_min_priority(). Use it to skip costly work for a dropped entry.
+//
+func (j *Journal) Enabled(p Priority) bool {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.enabled(p)
+}
+
+// enabled is Enabled with j.lock held.
+//
+func (j *Journal) enabled(p Priority) bool {
+	return j.min_priority == `` || p.level() <= j.min_priority.level()
+}
+
+// location_used reports whether the location of an entry is sent to the
+// journal or the writer. runtime.Callers is skipped otherwise. j.lock must be
+// held.
+//
+func (j *Journal) location_used(fields map[string]interface{}) bool {
+	if j.stacktrace != `` {
+		return true
+	}
+	package_lock.Lock()
+	defer package_lock.Unlock()
+	if !default_disable_journal {
+		return true
+	}
+	w := j.writer
+	if w == nil {
+		w = default_writer
+	}
+	if w == nil || j.writer_format != nil || !default_use_color {
+		return false
+	}
+	p, _ := fields[sd_priority].(Priority)
+	return default_color[p].Include_file
+}
+
+// code_fields reports whether the location fields are added to fields.
+// j.lock must be held.
+//
+func (j *Journal) code_fields(fields map[string]interface{}) bool {
+	if !j.add_go_code_fields {
+		return false
+	}
+	if j.code_fields_min == `` {
+		return true
+	}
+	p, ok := fields[sd_priority].(Priority)
+	return !ok || p.level() <= j.code_fields_min.level()
+}
+
 // Useful when file/line are not correct
 // default: 4
+// See Set_stack_skip().
 func (j *Journal) Stack_skip(skip int) *Journal {
 	j.lock.Lock()
 	defer j.lock.Unlock()
@@ -546,13 +1752,35 @@ func (j *Journal) Stack_skip(skip int) *Journal {
 	return j
 }
 
+// New_message_id returns a random MESSAGE_ID of 32 lowercase hex characters
+// like journalctl --new-id128; a version 4 UUID without dashes. Use it with
+// Set_message_id or Send_catalog.
+//
+func New_message_id() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ``, err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	id := hex.EncodeToString(b[:])
+	if !valid_message_id.MatchString(id) {
+		return ``, fmt.Errorf("invalid message id: %v", id)
+	}
+	return id, nil
+}
+
 // Set_message_id sets the systemd MESSAGE_ID (UUID) for all Journal
 // (Global) instances. Generate an application UUID with journalctl
 // --new-id128. See man journalctl.
 //
-// uuid is unset with ""
+// uuid is unset with "". An error is returned when uuid is not 32 lowercase
+// hex characters; i.e. a UUID with dashes. MESSAGE_ID is not changed.
 //
-func Set_message_id(uuid string) {
+func Set_message_id(uuid string) error {
+	if uuid != "" && !valid_message_id.MatchString(uuid) {
+		return fmt.Errorf("invalid MESSAGE_ID: %q: must be 32 lowercase hex characters", uuid)
+	}
 	package_lock.Lock()
 	defer package_lock.Unlock()
 	if uuid == "" {
@@ -560,6 +1788,7 @@ func Set_message_id(uuid string) {
 	} else {
 		id128 = map[string]interface{}{sd_message_id: uuid}
 	}
+	return nil
 }
 
 func Set_default_writer_stderr() option {
@@ -589,12 +1818,42 @@ func Set_default_writer(w io.Writer) option {
 //
 // example: map[Priority]string{Log_err: ansi.ColorCode("green")}
 //
+// Colors are not written when NO_COLOR is set or the writer is an *os.File
+// that is not a terminal; see ansi.ColorEnabledFor().
+//
 func Set_default_colors(colors map[Priority]Writer_option) {
 	package_lock.Lock()
 	defer package_lock.Unlock()
 	default_color = colors
 }
 
+// Set_field_colors writes fields after the message to the io.Writer, each
+// in a color. The map key is the field name and the value is an ANSI color
+// code; i.e. map[string]string{"PRIORITY": ansi.ColorCode("cyan"), Sd_tag:
+// ansi.ColorCode("green")} writes "message PRIORITY=info
+// SYSLOG_IDENTIFIER=app". Fields are written in the Set_field_order()
+// order. nil removes the fields. Default: nil.
+//
+func Set_field_colors(colors map[string]string) {
+	package_lock.Lock()
+	defer package_lock.Unlock()
+	default_field_colors = colors
+}
+
+// Set_field_order sets the order of fields written by Set_field_colors(),
+// Format_logfmt and Format_json. The names are first, in the given order,
+// followed by the other fields in name order. nil restores the default.
+// Default: MESSAGE, PRIORITY.
+//
+func Set_field_order(names []string) {
+	package_lock.Lock()
+	defer package_lock.Unlock()
+	if names == nil {
+		names = []string{Sd_message, sd_priority}
+	}
+	default_field_order = append([]string(nil), names...)
+}
+
 // Set default_remove_ansi_escape will set the default value for a new Journal.
 //
 func Set_default_remove_ansi_escape(rm remove_ansi_escape) {
@@ -619,11 +1878,321 @@ func Set_default_disable_journal(disable bool) option {
 // Send writes to the systemd-journal. The keys must be uppercase strings
 // without a leading _. The other send methods are easier to use. See Info(),
 // Infom(), Info_m_f(), etc. A MESSAGE key in field is the only required
-// field.
+// field. A field with a nil value is skipped, like Set_field(). Values are
+// string, Priority, []byte, or time.Duration, sent as an integer number of
+// microseconds; i.e. LATENCY=1500 for 1.5ms. Integers, floats and bools are
+// sent in decimal and error and fmt.Stringer values as Error() and String().
 //
 func (j *Journal) Send(fields map[string]interface{}) error {
+	return j.send(fields, nil)
+}
+
+// Log_pc sends msg with Priority p and fields. GO_FILE and GO_FUNC are the
+// location of pc, a program counter from runtime.Callers, instead of the
+// caller. It allows hooks for other logging packages to report where their
+// logger was called. msg is sent as is.
+//
+func (j *Journal) Log_pc(pc uintptr, p Priority, msg string, fields map[string]interface{}) error {
+	return j.send(j.copy([]map[string]interface{}{fields, j.load_defaults(msg, p)}...), pc_location(pc))
+}
+
+// send is Send with the code location for GO_FILE and GO_FUNC. The location
+// of the caller is used when loc is nil.
+//
+func (j *Journal) send(fields map[string]interface{}, loc *location) error {
+	_, err := j.send_n(fields, loc, 3)
+	return err
+}
+
+// send_n is send returning the number of fields sent. skip is the number of
+// frames between send_n and the caller of the Journal method.
+//
+func (j *Journal) send_n(fields map[string]interface{}, loc *location, skip int) (int, error) {
 	j.lock.Lock()
+	if p, ok := fields[sd_priority].(Priority); ok && !j.enabled(p) {
+		j.lock.Unlock()
+		return 0, nil
+	}
+	if !j.sampled(fields) {
+		j.lock.Unlock()
+		return 0, nil
+	}
+	loc = j.prepare(fields, loc, j.stack_skip+skip)
+	if a := j.async; a != nil {
+		e := async_entry{j: j, fields: make(map[string]interface{}, len(fields)), loc: loc}
+		for k, v := range fields {
+			e.fields[k] = v
+		}
+		j.lock.Unlock()
+		if j.enqueue(e) {
+			return len(e.fields), nil
+		}
+		// closed
+		j.lock.Lock()
+		fields = e.fields
+	}
 	defer j.lock.Unlock()
+	err := j.deliver(fields, loc)
+	if err == err_rate_limited {
+		return 0, nil
+	}
+	if err = j.count(err); err != nil {
+		return 0, err
+	}
+	return len(fields), nil
+}
+
+// Send_n is Send returning the number of fields sent to the journal,
+// including GO_FILE and GO_FUNC, after empty, nil and invalid fields are
+// removed. 0 is returned for a rate limited or sampled entry. A New_async()
+// Journal returns the number of queued fields.
+//
+func (j *Journal) Send_n(fields map[string]interface{}) (int, error) {
+	return j.send_n(fields, nil, 1)
+}
+
+// prepare returns the location of fields, found with runtime.Callers(skip)
+// when loc is nil, and adds STACKTRACE. j.lock must be held.
+//
+func (j *Journal) prepare(fields map[string]interface{}, loc *location, skip int) *location {
+	if loc == nil {
+		if j.code_fields(fields) && j.location_used(fields) {
+			fn, file, line := file_line(skip)
+			loc = &location{fn, file, line}
+		} else {
+			loc = &location{}
+		}
+	}
+	if j.stacktrace != `` {
+		if p, ok := fields[sd_priority].(Priority); ok && p.level() <= j.stacktrace.level() {
+			fields[sd_stacktrace] = stacktrace(skip, loc)
+		}
+	}
+	return loc
+}
+
+// Batch_error is returned by Send_batch. Index i is the error of entry i;
+// nil when the entry was sent.
+//
+type Batch_error []error
+
+func (e Batch_error) Error() string {
+	n := 0
+	var first error
+	for _, err := range e {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			n++
+		}
+	}
+	return fmt.Sprintf("%v of %v entries failed: %v", n, len(e), first)
+}
+
+// Send_batch sends entries like Send with one lock of the Journal. The
+// entries share the location of the caller. A Batch_error is returned when
+// an entry fails.
+//
+func (j *Journal) Send_batch(entries []map[string]interface{}) error {
+	errs := make(Batch_error, len(entries))
+	failed := false
+	j.lock.Lock()
+	// the caller location, for the entries that use it
+	var caller *location
+	locs := make([]*location, len(entries))
+	for i, fields := range entries {
+		locs[i] = &location{}
+		if j.code_fields(fields) && j.location_used(fields) {
+			if caller == nil {
+				fn, file, line := file_line(j.stack_skip - 1)
+				caller = &location{fn, file, line}
+			}
+			locs[i] = caller
+		}
+	}
+	if j.async != nil {
+		j.lock.Unlock()
+		for i, fields := range entries {
+			if errs[i] = j.send(fields, locs[i]); errs[i] != nil {
+				failed = true
+			}
+		}
+	} else {
+		for i, fields := range entries {
+			if p, ok := fields[sd_priority].(Priority); ok && !j.enabled(p) {
+				continue
+			}
+			if !j.sampled(fields) {
+				continue
+			}
+			l := j.prepare(fields, locs[i], j.stack_skip)
+			if errs[i] = j.count(j.deliver(fields, l)); errs[i] != nil {
+				failed = true
+			}
+		}
+		j.lock.Unlock()
+	}
+	if failed {
+		return errs
+	}
+	return nil
+}
+
+// Stats is a snapshot of Journal counters.
+type Stats struct {
+	// Entries sent to the writer and journal
+	Sent uint64
+	// Entries with a send error
+	Failed uint64
+	// Entries dropped from a full New_async() queue
+	Dropped uint64
+	// Entries suppressed by Set_rate_limit()
+	Rate_limited uint64
+}
+
+// Stats returns the counters of j. It is safe to call while logging.
+//
+func (j *Journal) Stats() Stats {
+	return Stats{
+		Sent:         atomic.LoadUint64(&j.sent),
+		Failed:       atomic.LoadUint64(&j.failed),
+		Dropped:      atomic.LoadUint64(&j.dropped),
+		Rate_limited: atomic.LoadUint64(&j.rate_limited),
+	}
+}
+
+// Last_sent returns when the last entry of j was sent; the zero time.Time
+// before the first. The time has a monotonic clock reading; see time.Since.
+// It is safe to call while logging.
+//
+func (j *Journal) Last_sent() time.Time {
+	t, _ := j.last_sent.Load().(time.Time)
+	return t
+}
+
+// field_value converts v to a string, Priority or []byte value. ok is false
+// for other types. time.Duration is the integer number of microseconds;
+// integers, floats and bools are formatted with strconv; error and
+// fmt.Stringer values are the Error() and String() results. r is nil for a
+// nil pointer error or fmt.Stringer; the field is dropped like a nil value.
+//
+func field_value(v interface{}) (r interface{}, ok bool) {
+	switch t := v.(type) {
+	case string, Priority, []byte:
+		return v, true
+	case time.Duration:
+		return strconv.FormatInt(t.Microseconds(), 10), true
+	case int:
+		return strconv.FormatInt(int64(t), 10), true
+	case int8:
+		return strconv.FormatInt(int64(t), 10), true
+	case int16:
+		return strconv.FormatInt(int64(t), 10), true
+	case int32:
+		return strconv.FormatInt(int64(t), 10), true
+	case int64:
+		return strconv.FormatInt(t, 10), true
+	case uint:
+		return strconv.FormatUint(uint64(t), 10), true
+	case uint8:
+		return strconv.FormatUint(uint64(t), 10), true
+	case uint16:
+		return strconv.FormatUint(uint64(t), 10), true
+	case uint32:
+		return strconv.FormatUint(uint64(t), 10), true
+	case uint64:
+		return strconv.FormatUint(t, 10), true
+	case float32:
+		return strconv.FormatFloat(float64(t), 'g', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(t, 'g', -1, 64), true
+	case bool:
+		return strconv.FormatBool(t), true
+	case error:
+		if nil_pointer(t) {
+			return nil, true
+		}
+		return t.Error(), true
+	case fmt.Stringer:
+		if nil_pointer(t) {
+			return nil, true
+		}
+		return t.String(), true
+	}
+	return nil, false
+}
+
+// nil_pointer reports whether v is a typed nil pointer, i.e. a nil *MyErr in
+// an error.
+//
+func nil_pointer(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// err_rate_limited is returned by deliver for a suppressed entry.
+var err_rate_limited = errors.New("rate limited")
+
+// count updates the sent and failed counters for a deliver result.
+//
+func (j *Journal) count(err error) error {
+	if err == err_rate_limited {
+		return nil
+	}
+	if err == nil {
+		atomic.AddUint64(&j.sent, 1)
+		j.last_sent.Store(time.Now())
+	} else {
+		atomic.AddUint64(&j.failed, 1)
+	}
+	return err
+}
+
+// deliver sends fields to the writer and the journal after the transform,
+// filter and rate limit steps. j.lock must be held.
+//
+func (j *Journal) deliver(fields map[string]interface{}, loc *location) error {
+	for k, v := range fields {
+		if fv, ok := field_value(v); ok {
+			v = fv
+			fields[k] = fv
+		}
+		if v == nil {
+			delete(fields, k)
+		}
+	}
+	if j.message_transform != nil {
+		if s, ok := fields[Sd_message].(string); ok {
+			fields[Sd_message] = j.message_transform(s)
+		}
+	}
+	j.filter(fields)
+	if !j.rate_allow(fields) {
+		atomic.AddUint64(&j.rate_limited, 1)
+		return err_rate_limited
+	}
+	return j.output(fields, loc)
+}
+
+// filter redacts, sanitizes and skips invalid fields. j.lock must be held.
+//
+func (j *Journal) filter(fields map[string]interface{}) {
+	if j.redactor != nil {
+		j.redact(fields)
+	}
+	if j.sanitize_fields {
+		j.sanitize(fields)
+	}
+	if j.skip_invalid {
+		j.skip_invalid_fields(fields)
+	}
+}
+
+// output sends filtered fields to the writer, the syslog remote and the
+// journal. j.lock must be held.
+//
+func (j *Journal) output(fields map[string]interface{}, loc *location) error {
 	package_lock.Lock()
 	disable_journal := default_disable_journal
 	package_lock.Unlock()
@@ -640,106 +2209,332 @@ func (j *Journal) Send(fields map[string]interface{}) error {
 		}
 		var cleaned_s string
 		// writer
-		if w != nil {
+		if w != nil && (j.writer_min == `` || priority.level() <= j.writer_min.level()) {
+			msg := s
 			if j.remove&Remove_writer != 0 {
-				cleaned_s = remove_re2.ReplaceAllLiteralString(s, ``)
-				if default_use_color {
-					package_lock.Lock()
-					var line string
-					if default_color[priority].Include_file {
-						if j.add_go_code_fields {
-							_, f, l := file_line(j.stack_skip)
-							line = fmt.Sprintf("%v:%v ", f, l)
-						}
-					}
-					reset := ``
-					if 0 < len(default_color[priority].Color) {
-						reset = ansi.Reset
-					}
-					fmt.Fprintf(w, "%v%v%v%v", default_color[priority].Color, line, cleaned_s, reset)
-					package_lock.Unlock()
-				} else {
-					fmt.Fprintf(w, cleaned_s)
+				cleaned_s = ansi.Strip(s)
+				msg = cleaned_s
+			}
+			if j.writer_format != nil {
+				msg = j.writer_format(fields)
+				if j.remove&Remove_writer != 0 {
+					msg = ansi.Strip(msg)
 				}
-			} else {
-				if default_use_color {
-					package_lock.Lock()
-					var line string
-					if default_color[priority].Include_file {
-						if j.add_go_code_fields {
-							_, f, l := file_line(j.stack_skip)
-							line = fmt.Sprintf("%v:%v ", f, l)
-						}
-					}
-					reset := ``
-					if 0 < len(default_color[priority].Color) {
-						reset = ansi.Reset
-					}
-					fmt.Fprintf(w, "%v%v%v%v", default_color[priority].Color, line, s, reset)
-					package_lock.Unlock()
-				} else {
-					fmt.Fprintf(w, s)
+				if !strings.HasSuffix(msg, "\n") {
+					msg += "\n"
 				}
+				io.WriteString(w, msg)
+			} else {
+				j.write(w, msg, priority, fields, loc)
 			}
 		}
+		if j.remote != nil {
+			remote_s := cleaned_s
+			if 0 == len(remote_s) {
+				remote_s = ansi.Strip(s)
+			}
+			j.remote.post(priority, strings.TrimSuffix(remote_s, "\n"))
+		}
 		if disable_journal {
 			return nil
 		}
 		// journal
 		if j.remove&Remove_journal != 0 {
 			if 0 == len(cleaned_s) {
-				fields[Sd_message] = remove_re2.ReplaceAllLiteralString(s, ``)
+				fields[Sd_message] = ansi.Strip(s)
 			} else {
 				fields[Sd_message] = cleaned_s
 			}
 		}
+		if j.trim_newline {
+			if m, ok := fields[Sd_message].(string); ok {
+				fields[Sd_message] = strings.TrimSuffix(m, "\n")
+			}
+		}
 	}
 	// journal
+	n := len(fields)
+	if loc.file != `` && j.code_fields(fields) {
+		if j.code_field_names {
+			if loc.fn != `` {
+				fields[sd_code_func] = loc.fn
+			}
+			fields[sd_code_file] = loc.file
+			fields[sd_code_line] = strconv.Itoa(loc.line)
+		} else {
+			if loc.fn != `` {
+				fields[sd_go_func] = loc.fn
+			}
+			if j.go_line {
+				fields[sd_go_file] = loc.file
+				fields[sd_go_line] = strconv.Itoa(loc.line)
+			} else {
+				fields[sd_go_file] = loc.file + `:` + strconv.Itoa(loc.line)
+			}
+			if j.code_line {
+				fields[sd_code_line] = strconv.Itoa(loc.line)
+			}
+		}
+	}
 	if max_fields < uint64(len(fields)) {
-		return errors.New(fmt.Sprintf("Field count cannot exceed %v: %v given", max_fields, len(fields)))
+		if j.split_entries {
+			return j.send_split(fields)
+		}
+		return fmt.Errorf("Field count cannot exceed %v: %v given and %v location fields", max_fields, n, len(fields)-n)
 	}
-	if j.add_go_code_fields {
-		fn, file, line := file_line(j.stack_skip)
-		fields[sd_go_func] = fn
-		fields[sd_go_file] = file + `:` + strconv.Itoa(line)
+	return j.send_entry(fields)
+}
+
+// split_fields are sent with each entry of a split entry.
+//
+var split_fields = []string{Sd_message, sd_priority, Sd_tag, sd_message_id, sd_go_func, sd_go_file, sd_go_line, sd_code_func, sd_code_file, sd_code_line}
+
+// send_split sends fields as entries of at most max_fields fields; see
+// Set_split_entries(). j.lock must be held.
+//
+func (j *Journal) send_split(fields map[string]interface{}) error {
+	group, err := New_message_id()
+	if err != nil {
+		return err
 	}
-	iov := C.malloc(C.size_t(C.sizeof_struct_iovec * len(fields)))
-	i := 0
-	defer func() {
-		for j := 0; j < i; j++ {
-			C.free(((*C.struct_iovec)(unsafe.Pointer(uintptr(iov) + uintptr(j)*C.sizeof_struct_iovec))).iov_base)
+	common := map[string]interface{}{Sd_entry_group: group}
+	for _, k := range split_fields {
+		if v, ok := fields[k]; ok {
+			common[k] = v
 		}
-		C.free(iov)
-	}()
+	}
+	var rest []string
+	for _, k := range field_names(fields) {
+		if _, ok := common[k]; !ok {
+			rest = append(rest, k)
+		}
+	}
+	// and ENTRY_PART
+	per := int(max_fields) - len(common) - 1
+	if per < 1 {
+		return fmt.Errorf("Field count cannot exceed %v: %v given", max_fields, len(fields))
+	}
+	for i := 0; 0 < len(rest); i++ {
+		part := make(map[string]interface{}, max_fields)
+		for k, v := range common {
+			part[k] = v
+		}
+		part[Sd_entry_part] = strconv.Itoa(i + 1)
+		c := rest
+		if per < len(c) {
+			c = c[:per]
+		}
+		rest = rest[len(c):]
+		for _, k := range c {
+			part[k] = fields[k]
+		}
+		if e := j.send_entry(part); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// send_entry sends fields to the sink. j.lock must be held.
+//
+func (j *Journal) send_entry(fields map[string]interface{}) error {
+	if err := j.check_field_size(fields); err != nil {
+		return err
+	}
+	if !j.allow_trusted {
+		for k := range fields {
+			if strings.HasPrefix(k, `_`) {
+				return fmt.Errorf("field violates regexp %v : %v", valid_field, k)
+			}
+		}
+	}
+	err := j.sink.send(fields)
+	var errno syscall.Errno
+	if j.fallback != nil && errors.As(err, &errno) {
+		if s, ok := fields[Sd_message].(string); ok {
+			if !strings.HasSuffix(s, "\n") {
+				s += "\n"
+			}
+			if _, werr := io.WriteString(j.fallback, s); werr == nil {
+				return nil
+			}
+		}
+	}
+	return err
+}
+
+// check_fields validates the names and values of fields. It returns the
+// size of the FIELD=value data.
+//
+func check_fields(fields map[string]interface{}) (size int, err error) {
+	if max_fields < uint64(len(fields)) {
+		return 0, fmt.Errorf("Field count cannot exceed %v: %v given", max_fields, len(fields))
+	}
+	size = 1
 	for k, v := range fields {
-		if valid_field.FindString(k) == "" {
-			return fmt.Errorf("field violates regexp %v : %v", valid_field, k)
+		if trusted_field.FindString(k) == "" {
+			return 0, fmt.Errorf("field violates regexp %v : %v", valid_field, k)
 		}
 		switch t := v.(type) {
+		case nil:
 		case string:
-			s := k + sd_field_name_sep_s + t
-			((*C.struct_iovec)(unsafe.Pointer(uintptr(iov) + uintptr(i)*C.sizeof_struct_iovec))).iov_base = unsafe.Pointer(C.CString(s))
-			((*C.struct_iovec)(unsafe.Pointer(uintptr(iov) + uintptr(i)*C.sizeof_struct_iovec))).iov_len = C.size_t(len(s))
+			size += len(k) + 1 + len(t)
 		case Priority:
-			s := k + sd_field_name_sep_s + string(t)
-			((*C.struct_iovec)(unsafe.Pointer(uintptr(iov) + uintptr(i)*C.sizeof_struct_iovec))).iov_base = unsafe.Pointer(C.CString(s))
-			((*C.struct_iovec)(unsafe.Pointer(uintptr(iov) + uintptr(i)*C.sizeof_struct_iovec))).iov_len = C.size_t(len(s))
+			size += len(k) + 1 + len(t)
 		case []byte:
-			b := bytes.Join([][]byte{[]byte(k), t}, sd_field_name_sep_b)
-			((*C.struct_iovec)(unsafe.Pointer(uintptr(iov) + uintptr(i)*C.sizeof_struct_iovec))).iov_base = C.CBytes(b)
-			((*C.struct_iovec)(unsafe.Pointer(uintptr(iov) + uintptr(i)*C.sizeof_struct_iovec))).iov_len = C.size_t(len(b))
+			size += len(k) + 1 + len(t)
 		default:
-			return fmt.Errorf("Error: Unsupported field value: key = %v", k)
+			return 0, fmt.Errorf("Error: Unsupported field value: key = %v", k)
+		}
+	}
+	return size, nil
+}
+
+// write writes msg to w with the Set_writer_header() header and the
+// Set_default_colors() color of priority, followed by the Set_field_colors()
+// fields and a newline. j.lock must be held.
+//
+func (j *Journal) write(w io.Writer, msg string, priority Priority, fields map[string]interface{}, loc *location) {
+	package_lock.Lock()
+	defer package_lock.Unlock()
+	use_color := default_use_color && ansi.ColorEnabledFor(w)
+	msg = strings.TrimSuffix(msg, "\n")
+	var header string
+	if j.writer_header != `` {
+		header = time.Now().Format(j.writer_header) + ` ` + priority.Name() + ` `
+	}
+	var colored_fields string
+	if 0 < len(default_field_colors) {
+		colored_fields = format_field_colors(fields, use_color)
+	}
+	if !use_color {
+		io.WriteString(w, header+msg+colored_fields+"\n")
+		return
+	}
+	var line string
+	if default_color[priority].Include_file {
+		if loc.file != `` && j.code_fields(fields) {
+			line = fmt.Sprintf("%v:%v ", loc.file, loc.line)
+		}
+	}
+	reset := ``
+	if 0 < len(default_color[priority].Color) {
+		reset = ansi.Reset
+	}
+	fmt.Fprintf(w, "%v%v%v%v%v%v\n", header, default_color[priority].Color, line, msg, reset, colored_fields)
+}
+
+// format_field_colors formats the Set_field_colors() fields as
+// " FIELD=value". package_lock must be held.
+//
+func format_field_colors(fields map[string]interface{}, use_color bool) string {
+	names := make([]string, 0, len(default_field_colors))
+	for k := range default_field_colors {
+		names = append(names, k)
+	}
+	order_names(names)
+	var b strings.Builder
+	for _, k := range names {
+		v, ok := fields[k]
+		if !ok {
+			continue
+		}
+		color := default_field_colors[k]
+		if !use_color {
+			color = ``
+		}
+		b.WriteString(` ` + color + k + `=` + field_string(v))
+		if color != `` {
+			b.WriteString(ansi.Reset)
+		}
+	}
+	return b.String()
+}
+
+// field_string formats a field value for a writer.
+//
+func field_string(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case Priority:
+		if name := t.Name(); name != `` {
+			return name
 		}
-		i++
+		return string(t)
+	case []byte:
+		return strconv.Quote(string(t))
+	default:
+		return fmt.Sprint(t)
 	}
-	n, _ := C.sd_journal_sendv((*C.struct_iovec)(iov), C.int(len(fields)))
-	if n != 0 {
-		return errors.New("Error with sd_journal_sendv arguments")
+}
+
+// check_field_size returns an error for the first field larger than
+// Set_max_field_size(). The size of a field is len(FIELD=value).
+//
+func (j *Journal) check_field_size(fields map[string]interface{}) error {
+	if j.max_field_size < 1 {
+		return nil
+	}
+	for k, v := range fields {
+		size := len(k) + 1
+		switch t := v.(type) {
+		case string:
+			size += len(t)
+		case Priority:
+			size += len(t)
+		case []byte:
+			size += len(t)
+		}
+		if j.max_field_size < size {
+			return fmt.Errorf("field %v size %v exceeds max field size %v", k, size, j.max_field_size)
+		}
 	}
 	return nil
 }
 
+// location is where a message was logged.
+type location struct {
+	fn   string
+	file string
+	line int
+}
+
+// pc_location returns the location of a program counter from
+// runtime.Callers.
+//
+func pc_location(pc uintptr) *location {
+	if pc == 0 {
+		return &location{}
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return &location{frame.Function, trim_go_path(frame.Function, frame.File), frame.Line}
+}
+
+// stacktrace formats the call stack from runtime.Callers(skip). Frames
+// before the loc function are omitted.
+//
+func stacktrace(skip int, loc *location) string {
+	pc := make([]uintptr, 64)
+	n := runtime.Callers(skip, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	found := loc == nil || loc.fn == ``
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		if !found && frame.Function == loc.fn {
+			found = true
+		}
+		if found {
+			fmt.Fprintf(&b, "%v\n\t%v:%v\n", frame.Function, trim_go_path(frame.Function, frame.File), frame.Line)
+		}
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
 // 4
 func file_line(skip int) (fn string, file string, line int) {
 	pc := make([]uintptr, 1)
@@ -752,18 +2547,122 @@ func file_line(skip int) (fn string, file string, line int) {
 	return frame.Function, trim_go_path(frame.Function, frame.File), frame.Line
 }
 
+// Valid_field reports whether name is a valid journal field name: uppercase
+// letters, digits and _, not starting with _. Send fails with an invalid
+// name; see Field_name.
+//
+func Valid_field(name string) bool {
+	return valid_field.MatchString(name)
+}
+
+// Field_name converts s into a valid journal field name. Letters are
+// uppercased, other invalid characters become _, and leading _ are removed.
+//
+func Field_name(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		if unicode.IsUpper(r) || ('0' <= r && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return strings.TrimLeft(b.String(), `_`)
+}
+
+// redact replaces the string and []byte fields with the Set_redactor()
+// value. j.lock must be held.
+//
+func (j *Journal) redact(fields map[string]interface{}) {
+	for k, v := range fields {
+		switch t := v.(type) {
+		case string:
+			fields[k] = string(j.redactor(k, []byte(t)))
+		case []byte:
+			fields[k] = j.redactor(k, t)
+		}
+	}
+}
+
+// valid_name reports whether k is a valid field name; see
+// Set_allow_trusted_fields.
+//
+func (j *Journal) valid_name(k string) bool {
+	if j.allow_trusted {
+		return trusted_field.MatchString(k)
+	}
+	return valid_field.MatchString(k)
+}
+
+// skip_invalid_fields removes the fields that check_fields() rejects and
+// adds INVALID_FIELDS.
+//
+func (j *Journal) skip_invalid_fields(fields map[string]interface{}) {
+	var invalid []string
+	for k, v := range fields {
+		valid := j.valid_name(k)
+		switch v.(type) {
+		case string, Priority, []byte:
+		default:
+			valid = false
+		}
+		if !valid {
+			delete(fields, k)
+			invalid = append(invalid, k)
+		}
+	}
+	if 0 < len(invalid) {
+		sort.Strings(invalid)
+		fields[sd_invalid_fields] = strings.Join(invalid, `,`)
+	}
+}
+
+// sanitize renames the invalid names of fields with Field_name().
+// Fields with an empty Field_name() are removed.
+//
+func (j *Journal) sanitize(fields map[string]interface{}) {
+	for k, v := range fields {
+		if j.valid_name(k) {
+			continue
+		}
+		delete(fields, k)
+		name := Field_name(k)
+		if name == `` {
+			continue
+		}
+		if _, ok := fields[name]; !ok {
+			fields[name] = v
+		}
+	}
+}
+
+// trim_go_path shortens file to the package import path of the function
+// name and the file base name; i.e. github.com/aletheia7/sd/v6/s.go. The
+// build directory is not included. main packages keep the last directory.
+//
 func trim_go_path(name, file string) string {
-	// From github.com/pkg/errors, BSD-2-Clause
-	const sep = "/"
-	goal := strings.Count(name, sep) + 2
-	i := len(file)
-	for n := 0; n < goal; n++ {
-		i = strings.LastIndex(file[:i], sep)
-		if i == -1 {
-			i = -len(sep)
-			break
+	if i := strings.IndexByte(name, '['); i != -1 {
+		name = name[:i]
+	}
+	slash := strings.LastIndex(name, "/")
+	dot := strings.IndexByte(name[slash+1:], '.')
+	if dot == -1 {
+		return file
+	}
+	pkg := strings.TrimSuffix(name[:slash+1+dot], "_test")
+	base := file
+	i := strings.LastIndex(file, "/")
+	if i != -1 {
+		base = file[i+1:]
+	}
+	if pkg == "main" {
+		// dir/file.go
+		if i > 0 {
+			if d := strings.LastIndex(file[:i], "/"); d != -1 {
+				return file[d+1:]
+			}
 		}
+		return file
 	}
-	file = file[i+len(sep):]
-	return file
+	return pkg + "/" + base
 }
@@ -0,0 +1,92 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+
+// Package sdlogrus provides a logrus hook that writes to the systemd-journal.
+package sdlogrus
+
+import (
+	"fmt"
+	"github.com/aletheia7/sd/v6"
+	"github.com/sirupsen/logrus"
+	"runtime"
+	"strings"
+)
+
+// Hook writes logrus entries to a sd.Journal.
+type Hook struct {
+	j *sd.Journal
+}
+
+// New_logrus_hook makes a logrus.Hook that writes entries to j. Entry.Data
+// keys are converted with sd.Field_name; i.e. request-id becomes REQUEST_ID.
+//
+//	log.AddHook(sdlogrus.New_logrus_hook(sd.New_journal()))
+//
+func New_logrus_hook(j *sd.Journal) logrus.Hook {
+	return &Hook{j: j}
+}
+
+// Levels returns all logrus levels.
+func (h *Hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *Hook) Fire(e *logrus.Entry) error {
+	fields := make(map[string]interface{}, len(e.Data))
+	for k, v := range e.Data {
+		name := sd.Field_name(k)
+		if name == "" {
+			continue
+		}
+		switch t := v.(type) {
+		case string:
+			fields[name] = t
+		case []byte:
+			fields[name] = t
+		case error:
+			fields[name] = t.Error()
+		default:
+			fields[name] = fmt.Sprint(t)
+		}
+	}
+	var pc uintptr
+	if e.Caller != nil {
+		pc = e.Caller.PC + 1
+	} else {
+		pc = caller_pc()
+	}
+	return h.j.Log_pc(pc, priority(e.Level), e.Message+"\n", fields)
+}
+
+func priority(l logrus.Level) sd.Priority {
+	switch l {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return sd.Log_crit
+	case logrus.ErrorLevel:
+		return sd.Log_err
+	case logrus.WarnLevel:
+		return sd.Log_warning
+	case logrus.InfoLevel:
+		return sd.Log_info
+	default:
+		return sd.Log_debug
+	}
+}
+
+// caller_pc returns the program counter of the first caller outside of
+// logrus. The pc is adjusted to look like a runtime.Callers return address.
+//
+func caller_pc() uintptr {
+	pc := make([]uintptr, 32)
+	n := runtime.Callers(3, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "github.com/sirupsen/logrus.") {
+			return frame.PC + 1
+		}
+		if !more {
+			return 0
+		}
+	}
+}
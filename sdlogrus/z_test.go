@@ -0,0 +1,101 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+
+package sdlogrus_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aletheia7/sd/v6"
+	"github.com/aletheia7/sd/v6/sdlogrus"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func new_logger() (*logrus.Logger, *sd.Memory_sink) {
+	ms := &sd.Memory_sink{}
+	j := sd.New_journal_sink(ms)
+	j.Option(sd.Set_min_priority(sd.Log_debug))
+	l := logrus.New()
+	l.SetOutput(ioutil.Discard)
+	l.SetLevel(logrus.TraceLevel)
+	l.AddHook(sdlogrus.New_logrus_hook(j))
+	return l, ms
+}
+
+func Test_hook(t *testing.T) {
+	l, ms := new_logger()
+	_, file, line, _ := runtime.Caller(0)
+	l.WithFields(logrus.Fields{"request-id": 7, "user": "bob", "err": errors.New("failed"), "-": "dropped"}).Warn("logrus test")
+	e := ms.Entries()
+	if len(e) != 1 {
+		t.Fatal(len(e))
+	}
+	for k, v := range map[string]interface{}{
+		"MESSAGE":    "logrus test",
+		"PRIORITY":   sd.Log_warning,
+		"REQUEST_ID": "7",
+		"USER":       "bob",
+		"ERR":        "failed",
+	} {
+		if e[0][k] != v {
+			t.Errorf("%v: %q", k, e[0][k])
+		}
+	}
+	if _, ok := e[0][""]; ok {
+		t.Errorf("%q", e[0])
+	}
+	if f, _ := e[0]["GO_FILE"].(string); !strings.HasSuffix(f, fmt.Sprintf("%v:%v", filepath.Base(file), line+1)) {
+		t.Errorf("GO_FILE: %q", f)
+	}
+}
+
+func Test_hook_report_caller(t *testing.T) {
+	l, ms := new_logger()
+	l.SetReportCaller(true)
+	_, file, line, _ := runtime.Caller(0)
+	l.Info("caller")
+	e := ms.Entries()
+	if len(e) != 1 {
+		t.Fatal(len(e))
+	}
+	if f, _ := e[0]["GO_FILE"].(string); !strings.HasSuffix(f, fmt.Sprintf("%v:%v", filepath.Base(file), line+1)) {
+		t.Errorf("GO_FILE: %q", f)
+	}
+	if fn, _ := e[0]["GO_FUNC"].(string); !strings.HasSuffix(fn, "Test_hook_report_caller") {
+		t.Errorf("GO_FUNC: %q", fn)
+	}
+}
+
+func Test_hook_levels(t *testing.T) {
+	l, ms := new_logger()
+	for _, c := range []struct {
+		level logrus.Level
+		p     sd.Priority
+	}{
+		{logrus.TraceLevel, sd.Log_debug},
+		{logrus.DebugLevel, sd.Log_debug},
+		{logrus.InfoLevel, sd.Log_info},
+		{logrus.WarnLevel, sd.Log_warning},
+		{logrus.ErrorLevel, sd.Log_err},
+		{logrus.FatalLevel, sd.Log_crit},
+		{logrus.PanicLevel, sd.Log_crit},
+	} {
+		func() {
+			defer func() { recover() }()
+			l.Log(c.level, "level")
+		}()
+		e := ms.Entries()
+		if len(e) == 0 {
+			t.Fatal(c.level)
+		}
+		if got := e[len(e)-1]["PRIORITY"]; got != c.p {
+			t.Errorf("%v: %q, expected %q", c.level, got, c.p)
+		}
+		ms.Reset()
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+
+// Package sdotel adds OpenTelemetry trace fields to systemd-journal entries.
+package sdotel
+
+import (
+	"context"
+	"fmt"
+	"github.com/aletheia7/sd/v6"
+	"go.opentelemetry.io/otel/trace"
+	"runtime"
+)
+
+const (
+	Sd_trace_id = "TRACE_ID"
+	Sd_span_id  = "SPAN_ID"
+)
+
+// Trace_fields returns TRACE_ID and SPAN_ID of the span in ctx; nil without
+// a valid span.
+//
+func Trace_fields(ctx context.Context) map[string]interface{} {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return map[string]interface{}{
+		Sd_trace_id: sc.TraceID().String(),
+		Sd_span_id:  sc.SpanID().String(),
+	}
+}
+
+// Set_otel adds TRACE_ID and SPAN_ID fields from the OpenTelemetry span in
+// the context of the *_ctx methods and the slog Handler of j; see
+// sd.Set_context_fields(). Entries without a valid span have no fields
+// added. false removes the context fields function of j.
+//
+func Set_otel(j *sd.Journal, enabled bool) {
+	if enabled {
+		j.Option(sd.Set_context_fields(Trace_fields))
+	} else {
+		j.Option(sd.Set_context_fields(nil))
+	}
+}
+
+// Journal is a sd.Journal with methods that add the context fields; see
+// sd.Journal.Context_fields().
+type Journal struct {
+	*sd.Journal
+}
+
+// New_otel_journal makes a Journal that sends to j with the TRACE_ID and
+// SPAN_ID fields of the context.
+//
+//	j := sdotel.New_otel_journal(sd.New_journal())
+//	j.Info_ctx(ctx, "request done")
+//
+func New_otel_journal(j *sd.Journal) *Journal {
+	Set_otel(j, true)
+	return &Journal{j}
+}
+
+// Send_ctx sends fields with the context fields added. fields is not
+// modified. An error is returned for the values Send rejects.
+//
+func (j *Journal) Send_ctx(ctx context.Context, fields map[string]interface{}) error {
+	return j.Send(j.ctx_fields(ctx, fields))
+}
+
+// ctx_fields returns a copy of fields with the context fields added.
+//
+func (j *Journal) ctx_fields(ctx context.Context, fields map[string]interface{}) map[string]interface{} {
+	c := j.Context_fields(ctx)
+	r := make(map[string]interface{}, len(fields)+len(c))
+	for k, v := range fields {
+		r[k] = v
+	}
+	for k, v := range c {
+		r[k] = v
+	}
+	return r
+}
+
+// log sends a fmt.Sprintln message of a with the location of the caller of
+// the *_ctx method.
+//
+func (j *Journal) log(ctx context.Context, p sd.Priority, fields map[string]interface{}, a []interface{}) error {
+	if !j.Enabled(p) {
+		return nil
+	}
+	pc := make([]uintptr, 1)
+	runtime.Callers(3, pc)
+	return j.Log_pc(pc[0], p, fmt.Sprintln(a...), j.ctx_fields(ctx, fields))
+}
+
+func (j *Journal) Emerg_ctx(ctx context.Context, a ...interface{}) error {
+	return j.log(ctx, sd.Log_emerg, nil, a)
+}
+
+func (j *Journal) Emerg_ctx_m(ctx context.Context, fields map[string]interface{}, a ...interface{}) error {
+	return j.log(ctx, sd.Log_emerg, fields, a)
+}
+
+func (j *Journal) Alert_ctx(ctx context.Context, a ...interface{}) error {
+	return j.log(ctx, sd.Log_alert, nil, a)
+}
+
+func (j *Journal) Alert_ctx_m(ctx context.Context, fields map[string]interface{}, a ...interface{}) error {
+	return j.log(ctx, sd.Log_alert, fields, a)
+}
+
+func (j *Journal) Crit_ctx(ctx context.Context, a ...interface{}) error {
+	return j.log(ctx, sd.Log_crit, nil, a)
+}
+
+func (j *Journal) Crit_ctx_m(ctx context.Context, fields map[string]interface{}, a ...interface{}) error {
+	return j.log(ctx, sd.Log_crit, fields, a)
+}
+
+func (j *Journal) Err_ctx(ctx context.Context, a ...interface{}) error {
+	return j.log(ctx, sd.Log_err, nil, a)
+}
+
+func (j *Journal) Err_ctx_m(ctx context.Context, fields map[string]interface{}, a ...interface{}) error {
+	return j.log(ctx, sd.Log_err, fields, a)
+}
+
+func (j *Journal) Warning_ctx(ctx context.Context, a ...interface{}) error {
+	return j.log(ctx, sd.Log_warning, nil, a)
+}
+
+func (j *Journal) Warning_ctx_m(ctx context.Context, fields map[string]interface{}, a ...interface{}) error {
+	return j.log(ctx, sd.Log_warning, fields, a)
+}
+
+func (j *Journal) Notice_ctx(ctx context.Context, a ...interface{}) error {
+	return j.log(ctx, sd.Log_notice, nil, a)
+}
+
+func (j *Journal) Notice_ctx_m(ctx context.Context, fields map[string]interface{}, a ...interface{}) error {
+	return j.log(ctx, sd.Log_notice, fields, a)
+}
+
+func (j *Journal) Info_ctx(ctx context.Context, a ...interface{}) error {
+	return j.log(ctx, sd.Log_info, nil, a)
+}
+
+func (j *Journal) Info_ctx_m(ctx context.Context, fields map[string]interface{}, a ...interface{}) error {
+	return j.log(ctx, sd.Log_info, fields, a)
+}
+
+func (j *Journal) Debug_ctx(ctx context.Context, a ...interface{}) error {
+	return j.log(ctx, sd.Log_debug, nil, a)
+}
+
+func (j *Journal) Debug_ctx_m(ctx context.Context, fields map[string]interface{}, a ...interface{}) error {
+	return j.log(ctx, sd.Log_debug, fields, a)
+}
@@ -0,0 +1,83 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+
+package sdotel_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/aletheia7/sd/v6"
+	"github.com/aletheia7/sd/v6/sdotel"
+	"go.opentelemetry.io/otel/trace"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func span_ctx() context.Context {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanID:  trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+	})
+	return trace.ContextWithSpanContext(context.Background(), sc)
+}
+
+func Test_Set_otel(t *testing.T) {
+	ms := &sd.Memory_sink{}
+	j := sdotel.New_otel_journal(sd.New_journal_sink(ms))
+	_, file, line, _ := runtime.Caller(0)
+	if err := j.Info_ctx(span_ctx(), "otel"); err != nil {
+		t.Fatal(err)
+	}
+	j.Warning_ctx_m(context.Background(), map[string]interface{}{"UNIT": "a"}, "no span")
+	sdotel.Set_otel(j.Journal, false)
+	j.Info_ctx(span_ctx(), "disabled")
+	e := ms.Entries()
+	if len(e) != 3 {
+		t.Fatal(len(e))
+	}
+	if e[0][sdotel.Sd_trace_id] != "0102030405060708090a0b0c0d0e0f10" || e[0][sdotel.Sd_span_id] != "0102030405060708" || e[0]["MESSAGE"] != "otel" || e[0]["PRIORITY"] != sd.Log_info {
+		t.Errorf("%q", e[0])
+	}
+	if f, _ := e[0]["GO_FILE"].(string); !strings.HasSuffix(f, fmt.Sprintf("%v:%v", filepath.Base(file), line+1)) {
+		t.Errorf("GO_FILE: %q", f)
+	}
+	if _, ok := e[1][sdotel.Sd_trace_id]; ok || e[1]["UNIT"] != "a" || e[1]["PRIORITY"] != sd.Log_warning {
+		t.Errorf("%q", e[1])
+	}
+	if _, ok := e[2][sdotel.Sd_trace_id]; ok {
+		t.Errorf("%q", e[2])
+	}
+}
+
+func Test_Send_ctx(t *testing.T) {
+	ms := &sd.Memory_sink{}
+	j := sdotel.New_otel_journal(sd.New_journal_sink(ms))
+	fields := map[string]interface{}{"MESSAGE": "ctx", "COUNT": 3}
+	if err := j.Send_ctx(span_ctx(), fields); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields[sdotel.Sd_trace_id]; ok {
+		t.Errorf("fields modified: %v", fields)
+	}
+	e := ms.Entries()
+	if len(e) != 1 || e[0]["COUNT"] != "3" || e[0][sdotel.Sd_trace_id] == nil {
+		t.Errorf("%v", e)
+	}
+	bad := map[string]interface{}{"MESSAGE": "bad", "X": struct{}{}}
+	err, ctx_err := j.Send(bad), j.Send_ctx(span_ctx(), bad)
+	if err == nil || ctx_err == nil || err.Error() != ctx_err.Error() {
+		t.Errorf("%v, %v", err, ctx_err)
+	}
+}
+
+func Test_Set_min_priority(t *testing.T) {
+	ms := &sd.Memory_sink{}
+	j := sdotel.New_otel_journal(sd.New_journal_sink(ms))
+	j.Option(sd.Set_min_priority(sd.Log_info))
+	j.Debug_ctx(span_ctx(), "debug")
+	if e := ms.Entries(); len(e) != 0 {
+		t.Errorf("%q", e)
+	}
+}
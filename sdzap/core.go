@@ -0,0 +1,140 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+
+// Package sdzap provides a zap core that writes to the systemd-journal.
+package sdzap
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aletheia7/sd/v6"
+	"go.uber.org/zap/zapcore"
+	"runtime"
+	"strings"
+)
+
+// Core is a zapcore.Core that writes entries to a sd.Journal.
+type Core struct {
+	zapcore.LevelEnabler
+	j      *sd.Journal
+	fields map[string]interface{}
+}
+
+// New_zap_core makes a zapcore.Core that writes entries enabled by enab to j.
+// Field keys are converted with sd.Field_name; nested objects and namespaces
+// prefix their keys: zap.Namespace("req"), zap.Int("id", 1) is REQ_ID.
+// GO_FILE and GO_FUNC use the zap caller when zap.AddCaller() is used.
+//
+//	l := zap.New(sdzap.New_zap_core(sd.New_journal(), zapcore.InfoLevel))
+//
+func New_zap_core(j *sd.Journal, enab zapcore.LevelEnabler) zapcore.Core {
+	return &Core{LevelEnabler: enab, j: j, fields: map[string]interface{}{}}
+}
+
+func (c *Core) With(fields []zapcore.Field) zapcore.Core {
+	return &Core{LevelEnabler: c.LevelEnabler, j: c.j, fields: c.encode(fields)}
+}
+
+func (c *Core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *Core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	m := c.encode(fields)
+	if ent.Stack != "" {
+		m["STACKTRACE"] = ent.Stack
+	}
+	var pc uintptr
+	if ent.Caller.Defined && ent.Caller.PC != 0 {
+		pc = ent.Caller.PC + 1
+	} else {
+		pc = caller_pc()
+	}
+	return c.j.Log_pc(pc, priority(ent.Level), ent.Message+"\n", m)
+}
+
+// Sync does nothing; journal entries are not buffered.
+func (c *Core) Sync() error {
+	return nil
+}
+
+// encode returns a copy of the accumulated fields with fields added.
+func (c *Core) encode(fields []zapcore.Field) map[string]interface{} {
+	r := make(map[string]interface{}, len(c.fields)+len(fields))
+	for k, v := range c.fields {
+		r[k] = v
+	}
+	if len(fields) == 0 {
+		return r
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	flatten(r, "", enc.Fields)
+	return r
+}
+
+func flatten(dest map[string]interface{}, prefix string, src map[string]interface{}) {
+	for k, v := range src {
+		if m, ok := v.(map[string]interface{}); ok {
+			flatten(dest, prefix+k+"_", m)
+			continue
+		}
+		name := sd.Field_name(prefix + k)
+		if name == "" {
+			continue
+		}
+		switch t := v.(type) {
+		case string:
+			dest[name] = t
+		case []byte:
+			dest[name] = t
+		case []interface{}:
+			if b, err := json.Marshal(t); err == nil {
+				dest[name] = b
+			} else {
+				dest[name] = fmt.Sprint(t)
+			}
+		default:
+			dest[name] = fmt.Sprint(t)
+		}
+	}
+}
+
+func priority(l zapcore.Level) sd.Priority {
+	switch l {
+	case zapcore.DebugLevel:
+		return sd.Log_debug
+	case zapcore.InfoLevel:
+		return sd.Log_info
+	case zapcore.WarnLevel:
+		return sd.Log_warning
+	case zapcore.ErrorLevel:
+		return sd.Log_err
+	default:
+		// DPanic, Panic, Fatal
+		return sd.Log_crit
+	}
+}
+
+// caller_pc returns the program counter of the first caller outside of zap.
+// The pc is adjusted to look like a runtime.Callers return address.
+//
+func caller_pc() uintptr {
+	pc := make([]uintptr, 32)
+	n := runtime.Callers(3, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "go.uber.org/zap") {
+			return frame.PC + 1
+		}
+		if !more {
+			return 0
+		}
+	}
+}
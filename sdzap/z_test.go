@@ -0,0 +1,97 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+
+package sdzap_test
+
+import (
+	"fmt"
+	"github.com/aletheia7/sd/v6"
+	"github.com/aletheia7/sd/v6/sdzap"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func new_core(enab zapcore.LevelEnabler) (zapcore.Core, *sd.Memory_sink) {
+	ms := &sd.Memory_sink{}
+	j := sd.New_journal_sink(ms)
+	j.Option(sd.Set_min_priority(sd.Log_debug))
+	return sdzap.New_zap_core(j, enab), ms
+}
+
+func Test_core(t *testing.T) {
+	c, ms := new_core(zapcore.DebugLevel)
+	l := zap.New(c, zap.AddCaller())
+	_, file, line, _ := runtime.Caller(0)
+	l.With(zap.String("user", "bob")).Info("zap test", zap.Int("request-id", 7), zap.Bool("ok", true), zap.Namespace("http"), zap.String("method", "GET"))
+	if err := l.Sync(); err != nil {
+		t.Error(err)
+	}
+	l.Info("no with")
+	e := ms.Entries()
+	if len(e) != 2 {
+		t.Fatal(len(e))
+	}
+	for k, v := range map[string]interface{}{
+		"MESSAGE":     "zap test",
+		"PRIORITY":    sd.Log_info,
+		"USER":        "bob",
+		"REQUEST_ID":  "7",
+		"OK":          "true",
+		"HTTP_METHOD": "GET",
+	} {
+		if e[0][k] != v {
+			t.Errorf("%v: %q", k, e[0][k])
+		}
+	}
+	if f, _ := e[0]["GO_FILE"].(string); !strings.HasSuffix(f, fmt.Sprintf("%v:%v", filepath.Base(file), line+1)) {
+		t.Errorf("GO_FILE: %q", f)
+	}
+	if _, ok := e[1]["USER"]; ok {
+		t.Errorf("With() field on parent: %q", e[1])
+	}
+}
+
+func Test_core_With(t *testing.T) {
+	c, ms := new_core(zapcore.DebugLevel)
+	l := zap.New(c).With(zap.String("user", "bob")).With(zap.Namespace("req"), zap.Int("id", 1))
+	l.Info("with", zap.String("path", "/"))
+	e := ms.Entries()
+	if len(e) != 1 || e[0]["USER"] != "bob" || e[0]["REQ_ID"] != "1" || e[0]["PATH"] != "/" {
+		t.Errorf("%q", e)
+	}
+}
+
+func Test_core_levels(t *testing.T) {
+	c, ms := new_core(zapcore.DebugLevel)
+	for _, x := range []struct {
+		level zapcore.Level
+		p     sd.Priority
+	}{
+		{zapcore.DebugLevel, sd.Log_debug},
+		{zapcore.InfoLevel, sd.Log_info},
+		{zapcore.WarnLevel, sd.Log_warning},
+		{zapcore.ErrorLevel, sd.Log_err},
+		{zapcore.DPanicLevel, sd.Log_crit},
+		{zapcore.PanicLevel, sd.Log_crit},
+		{zapcore.FatalLevel, sd.Log_crit},
+	} {
+		if err := c.Write(zapcore.Entry{Level: x.level, Message: "level"}, nil); err != nil {
+			t.Fatal(err)
+		}
+		e := ms.Entries()
+		if got := e[len(e)-1]["PRIORITY"]; got != x.p {
+			t.Errorf("%v: %q, expected %q", x.level, got, x.p)
+		}
+	}
+	c, ms = new_core(zapcore.WarnLevel)
+	l := zap.New(c)
+	l.Info("disabled")
+	l.Warn("enabled")
+	if e := ms.Entries(); len(e) != 1 || e[0]["MESSAGE"] != "enabled" {
+		t.Errorf("%q", e)
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build linux && cgo && !sd_nosend
+// +build linux,cgo,!sd_nosend
+
+package sd
+
+/*
+#define SD_JOURNAL_SUPPRESS_LOCATION
+#include <systemd/sd-journal.h>
+
+static int journal_print(int priority, const char *message) {
+	return sd_journal_print(priority, "%s", message);
+}
+*/
+import "C"
+
+func journal_sendv(iov *C.struct_iovec, n C.int) C.int {
+	return C.sd_journal_sendv(iov, n)
+}
+
+func journal_print(priority C.int, message *C.char) C.int {
+	return C.journal_print(priority, message)
+}
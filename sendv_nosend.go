@@ -0,0 +1,24 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build linux && cgo && sd_nosend
+// +build linux,cgo,sd_nosend
+
+package sd
+
+/*
+#include <sys/uio.h>
+*/
+import "C"
+
+// journal_sendv does not send to the journal. Build with -tags sd_nosend to
+// benchmark without journald:
+//
+//	go test -tags sd_nosend -run - -bench .
+//
+func journal_sendv(iov *C.struct_iovec, n C.int) C.int {
+	return 0
+}
+
+func journal_print(priority C.int, message *C.char) C.int {
+	return 0
+}
@@ -0,0 +1,148 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build linux && cgo
+// +build linux,cgo
+
+package sd
+
+/*
+#cgo pkg-config: libsystemd
+#include <stdlib.h>
+#include <systemd/sd-journal.h>
+#include <unistd.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var max_fields = uint64(C.sysconf(C._SC_IOV_MAX))
+
+type journal_sink struct{}
+
+func (journal_sink) send(fields map[string]interface{}) error {
+	return sendv(fields)
+}
+
+// print_sink sends MESSAGE and PRIORITY only entries with sd_journal_print.
+type print_sink struct{}
+
+func (print_sink) send(fields map[string]interface{}) error {
+	if !print_only(fields) {
+		return sendv(fields)
+	}
+	msg := fields[Sd_message].(string)
+	if _, err := check_fields(fields); err != nil {
+		return err
+	}
+	p, _ := fields[sd_priority].(Priority)
+	n, err := strconv.Atoi(string(p))
+	if err != nil {
+		n, _ = strconv.Atoi(string(Log_info))
+	}
+	cs := C.CString(msg)
+	defer C.free(unsafe.Pointer(cs))
+	// sd_journal_print returns a negative errno value on failure
+	if r := journal_print(C.int(n), cs); r < 0 {
+		return fmt.Errorf("sd_journal_print: %w", syscall.Errno(-r))
+	}
+	return nil
+}
+
+// send_buffer is C memory for sd_journal_sendv: an iovec array of max_fields
+// and the FIELD=value data. send_buffers reuses them; a finalizer frees the
+// memory of a send_buffer dropped by the pool. A send_buffer with more than
+// max_pooled_size bytes of data is freed after use instead of pooled.
+//
+type send_buffer struct {
+	iov  unsafe.Pointer
+	data unsafe.Pointer
+	size int
+}
+
+const (
+	max_pooled_size = 1 << 20
+	// max_sendv_size is the size of the array data is sliced from
+	max_sendv_size = 1 << 30
+)
+
+var send_buffers = sync.Pool{
+	New: func() interface{} {
+		b := &send_buffer{iov: C.malloc(C.size_t(C.sizeof_struct_iovec * max_fields))}
+		runtime.SetFinalizer(b, (*send_buffer).free)
+		return b
+	},
+}
+
+func (b *send_buffer) free() {
+	C.free(b.iov)
+	C.free(b.data)
+}
+
+// put returns b to send_buffers, or frees b when its data is larger than
+// max_pooled_size.
+//
+func (b *send_buffer) put() {
+	if b.size <= max_pooled_size {
+		send_buffers.Put(b)
+		return
+	}
+	runtime.SetFinalizer(b, nil)
+	b.free()
+}
+
+// reserve grows data to at least size bytes.
+//
+func (b *send_buffer) reserve(size int) {
+	if size <= b.size {
+		return
+	}
+	C.free(b.data)
+	b.data = C.malloc(C.size_t(size))
+	b.size = size
+}
+
+// sendv sends fields with sd_journal_sendv.
+//
+func sendv(fields map[string]interface{}) error {
+	size, err := check_fields(fields)
+	if err != nil {
+		return err
+	}
+	if max_sendv_size < size {
+		return fmt.Errorf("Entry size cannot exceed %v: %v given", max_sendv_size, size)
+	}
+	b := send_buffers.Get().(*send_buffer)
+	defer b.put()
+	b.reserve(size)
+	names := field_names(fields)
+	iov := (*[1 << 20]C.struct_iovec)(b.iov)[:len(names):len(names)]
+	data := (*[1 << 30]byte)(b.data)[:size:size]
+	n := 0
+	for i, k := range names {
+		start := n
+		n += copy(data[n:], k)
+		n += copy(data[n:], sd_field_name_sep_b)
+		switch t := fields[k].(type) {
+		case string:
+			n += copy(data[n:], t)
+		case Priority:
+			n += copy(data[n:], t)
+		case []byte:
+			n += copy(data[n:], t)
+		}
+		iov[i].iov_base = unsafe.Pointer(&data[start])
+		iov[i].iov_len = C.size_t(n - start)
+	}
+	// sd_journal_sendv returns a negative errno value on failure
+	if r := journal_sendv((*C.struct_iovec)(b.iov), C.int(len(names))); r < 0 {
+		return fmt.Errorf("sd_journal_sendv: %w", syscall.Errno(-r))
+	}
+	return nil
+}
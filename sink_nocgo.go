@@ -0,0 +1,23 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build linux && !cgo
+// +build linux,!cgo
+
+package sd
+
+// Without cgo entries are sent to journald with the native protocol; see
+// socket_send(). libsystemd is not needed.
+
+// max_fields is IOV_MAX of linux.
+var max_fields = uint64(1024)
+
+type journal_sink struct{}
+
+func (journal_sink) send(fields map[string]interface{}) error {
+	return socket_send(fields)
+}
+
+// print_sink is journal_sink; sd_journal_print requires libsystemd.
+type print_sink struct {
+	journal_sink
+}
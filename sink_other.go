@@ -0,0 +1,22 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build !linux
+// +build !linux
+
+package sd
+
+// There is no journal; entries are validated and dropped. The writer still
+// receives entries; see Set_default_writer_stderr().
+
+var max_fields = uint64(1024)
+
+type journal_sink struct{}
+
+func (journal_sink) send(fields map[string]interface{}) error {
+	_, err := check_fields(fields)
+	return err
+}
+
+type print_sink struct {
+	journal_sink
+}
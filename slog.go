@@ -0,0 +1,108 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build go1.21
+// +build go1.21
+
+package sd
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Handler is a log/slog Handler that writes records to a Journal.
+type Handler struct {
+	j      *Journal
+	prefix string
+	fields map[string]interface{}
+}
+
+// New_slog_handler makes a slog.Handler that writes to j. Record levels map
+// to Log_debug, Log_info, Log_warning and Log_err. The record message becomes
+// MESSAGE. Attribute keys become journal field names; they are uppercased
+// and groups prefix their keys: slog.Group("req", "id", 1) is REQ_ID. The
+// Set_context_fields() fields of the context are added.
+//
+func New_slog_handler(j *Journal) slog.Handler {
+	return &Handler{j: j, fields: map[string]interface{}{}}
+}
+
+func (h *Handler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.j.Enabled(slog_priority(l))
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	fields := make(map[string]interface{}, len(h.fields)+r.NumAttrs())
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		add_slog_attr(fields, h.prefix, a)
+		return true
+	})
+	for k, v := range h.j.Context_fields(ctx) {
+		fields[k] = v
+	}
+	return h.j.send(h.j.copy([]map[string]interface{}{fields, h.j.load_defaults(r.Message+"\n", slog_priority(r.Level))}...), pc_location(r.PC))
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	r := &Handler{j: h.j, prefix: h.prefix, fields: make(map[string]interface{}, len(h.fields)+len(attrs))}
+	for k, v := range h.fields {
+		r.fields[k] = v
+	}
+	for _, a := range attrs {
+		add_slog_attr(r.fields, h.prefix, a)
+	}
+	return r
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &Handler{j: h.j, prefix: h.prefix + name + "_", fields: h.fields}
+}
+
+func slog_priority(l slog.Level) Priority {
+	switch {
+	case l < slog.LevelInfo:
+		return Log_debug
+	case l < slog.LevelWarn:
+		return Log_info
+	case l < slog.LevelError:
+		return Log_warning
+	default:
+		return Log_err
+	}
+}
+
+func add_slog_attr(fields map[string]interface{}, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "_"
+		}
+		for _, ga := range a.Value.Group() {
+			add_slog_attr(fields, prefix, ga)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	name := Field_name(prefix + a.Key)
+	if name == "" {
+		return
+	}
+	if b, ok := a.Value.Any().([]byte); ok {
+		fields[name] = b
+	} else if a.Value.Kind() == slog.KindDuration {
+		fields[name] = a.Value.Duration()
+	} else {
+		fields[name] = a.Value.String()
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build go1.21
+// +build go1.21
+
+package sd_test
+
+import (
+	"context"
+	"fmt"
+	. "github.com/aletheia7/sd/v6"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_slog_handler(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	j.Option(Set_min_priority(Log_debug))
+	l := slog.New(New_slog_handler(j))
+	_, file, line, _ := runtime.Caller(0)
+	l.With("user", "bob").WithGroup("req").Info("slog test", "id", 7, slog.Group("http", "method", "GET"), "wait", 1500*time.Microsecond)
+	e := ms.Entries()
+	if len(e) != 1 {
+		t.Fatal(len(e))
+	}
+	for k, v := range map[string]interface{}{
+		"MESSAGE":         "slog test",
+		"PRIORITY":        Log_info,
+		"USER":            "bob",
+		"REQ_ID":          "7",
+		"REQ_HTTP_METHOD": "GET",
+		"REQ_WAIT":        "1500",
+	} {
+		if e[0][k] != v {
+			t.Errorf("%v: %q", k, e[0][k])
+		}
+	}
+	if f, _ := e[0]["GO_FILE"].(string); !strings.HasSuffix(f, fmt.Sprintf("%v:%v", filepath.Base(file), line+1)) {
+		t.Errorf("GO_FILE: %q", f)
+	}
+	for _, c := range []struct {
+		level slog.Level
+		p     Priority
+	}{
+		{slog.LevelDebug, Log_debug},
+		{slog.LevelInfo, Log_info},
+		{slog.LevelWarn, Log_warning},
+		{slog.LevelError, Log_err},
+		{slog.LevelError + 4, Log_err},
+	} {
+		if err := l.Handler().Handle(context.Background(), slog.NewRecord(time.Now(), c.level, "level", 0)); err != nil {
+			t.Fatal(err)
+		}
+		e = ms.Entries()
+		if got := e[len(e)-1]["PRIORITY"]; got != c.p {
+			t.Errorf("%v: %q, expected %q", c.level, got, c.p)
+		}
+	}
+}
+
+func Test_slog_handler_Enabled(t *testing.T) {
+	j := New_journal_sink(&Memory_sink{})
+	j.Option(Set_min_priority(Log_warning))
+	h := New_slog_handler(j)
+	if h.Enabled(context.Background(), slog.LevelInfo) || !h.Enabled(context.Background(), slog.LevelWarn) {
+		t.Error("Enabled")
+	}
+}
+
+type request_key struct{}
+
+func Test_slog_handler_Set_context_fields(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	j.Option(Set_context_fields(func(ctx context.Context) map[string]interface{} {
+		if id, ok := ctx.Value(request_key{}).(string); ok {
+			return map[string]interface{}{"REQUEST_ID": id}
+		}
+		return nil
+	}))
+	l := slog.New(New_slog_handler(j))
+	l.InfoContext(context.WithValue(context.Background(), request_key{}, "7"), "ctx")
+	l.InfoContext(context.Background(), "no ctx")
+	e := ms.Entries()
+	if len(e) != 2 || e[0]["REQUEST_ID"] != "7" || e[1]["REQUEST_ID"] != nil {
+		t.Errorf("%q", e)
+	}
+	if j.Context_fields(nil) != nil {
+		t.Error("nil ctx")
+	}
+}
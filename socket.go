@@ -0,0 +1,109 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build linux
+// +build linux
+
+package sd
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// journal_socket is the journald native protocol socket; see man
+// systemd-journald.service.
+var journal_socket = "/run/systemd/journal/socket"
+
+var socket struct {
+	lock sync.Mutex
+	conn *net.UnixConn
+}
+
+// socket_send sends fields to journald with the native protocol without
+// libsystemd. The socket is redialed and the entry sent again once after a
+// journald restart. Like sd_journal_sendv, nil is returned when journald is
+// not running.
+//
+func socket_send(fields map[string]interface{}) error {
+	size, err := check_fields(fields)
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 0, size+len(fields)*9)
+	for _, k := range field_names(fields) {
+		b = append_value(b, k, fields[k])
+	}
+	socket.lock.Lock()
+	defer socket.lock.Unlock()
+	err = socket_write(b)
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ENOTCONN) || errors.Is(err, syscall.EPIPE) {
+		err = socket_write(b)
+	}
+	if errors.Is(err, syscall.ENOENT) {
+		return nil
+	}
+	return err
+}
+
+// socket_write sends b on socket.conn; it is dialed when nil and closed
+// after an error. socket.lock must be held.
+//
+func socket_write(b []byte) (err error) {
+	if socket.conn == nil {
+		socket.conn, err = net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journal_socket, Net: "unixgram"})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = socket.conn.Write(b)
+	if errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS) {
+		err = socket_send_memfd(socket.conn, b)
+	}
+	if err != nil {
+		socket.conn.Close()
+		socket.conn = nil
+	}
+	return err
+}
+
+// socket_send_memfd sends b in a sealed memfd when b is too large for a
+// datagram, like sd_journal_sendv.
+//
+func socket_send_memfd(c *net.UnixConn, b []byte) error {
+	fd, err := unix.MemfdCreate("journal-message", unix.MFD_ALLOW_SEALING|unix.MFD_CLOEXEC)
+	if err != nil {
+		return err
+	}
+	f := os.NewFile(uintptr(fd), "journal-message")
+	defer f.Close()
+	if _, err = f.Write(b); err != nil {
+		return err
+	}
+	if _, err = unix.FcntlInt(uintptr(fd), unix.F_ADD_SEALS, unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_WRITE|unix.F_SEAL_SEAL); err != nil {
+		return err
+	}
+	return send_rights(c, nil, syscall.UnixRights(fd))
+}
+
+// send_rights sends b and oob on the connected datagram socket c;
+// WriteMsgUnix does not allow a connected datagram socket.
+//
+func send_rights(c *net.UnixConn, b, oob []byte) error {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err = rc.Write(func(fd uintptr) bool {
+		serr = syscall.Sendmsg(int(fd), b, oob, nil, 0)
+		return serr != syscall.EAGAIN
+	}); err != nil {
+		return err
+	}
+	return serr
+}
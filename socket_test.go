@@ -0,0 +1,60 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build linux
+// +build linux
+
+package sd_test
+
+import (
+	. "github.com/aletheia7/sd/v6"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_socket_send_restart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "socket")
+	defer Set_journal_socket(Set_journal_socket(path))
+	listen := func() *net.UnixConn {
+		c, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	read := func(c *net.UnixConn) string {
+		b := make([]byte, 1024)
+		c.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := c.Read(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b[:n])
+	}
+	c := listen()
+	if err := Socket_send(map[string]interface{}{"MESSAGE": "one"}); err != nil {
+		t.Fatal(err)
+	}
+	if s := read(c); !strings.Contains(s, "MESSAGE=one\n") {
+		t.Errorf("%q", s)
+	}
+	// journald restart
+	c.Close()
+	os.Remove(path)
+	c = listen()
+	if err := Socket_send(map[string]interface{}{"MESSAGE": "two"}); err != nil {
+		t.Fatal(err)
+	}
+	if s := read(c); !strings.Contains(s, "MESSAGE=two\n") {
+		t.Errorf("%q", s)
+	}
+	// journald stopped
+	c.Close()
+	os.Remove(path)
+	if err := Socket_send(map[string]interface{}{"MESSAGE": "three"}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+
+package sd
+
+import "fmt"
+
+var std *Journal
+
+// Set_default sets the Journal of the package level send functions, Info(),
+// Err(), etc. nil restores a Journal made by Default() on the next send.
+//
+func Set_default(j *Journal) {
+	package_lock.Lock()
+	defer package_lock.Unlock()
+	std = j
+}
+
+// default_journal returns the Set_default() Journal, made by Default() when
+// nil.
+//
+func default_journal() *Journal {
+	package_lock.Lock()
+	j := std
+	package_lock.Unlock()
+	if j != nil {
+		return j
+	}
+	j = Default()
+	package_lock.Lock()
+	defer package_lock.Unlock()
+	if std == nil {
+		std = j
+	}
+	return std
+}
+
+// Emerg is Journal.Emerg for the Set_default() Journal. The other package
+// level functions, Info(), Infof(), etc., are the same for their Journal
+// method.
+//
+func Emerg(a ...interface{}) error {
+	j := default_journal()
+	if !j.Enabled(Log_emerg) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fmt.Sprintln(a...), Log_emerg))
+}
+
+func Alert(a ...interface{}) error {
+	j := default_journal()
+	if !j.Enabled(Log_alert) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fmt.Sprintln(a...), Log_alert))
+}
+
+func Alertf(format string, a ...interface{}) error {
+	j := default_journal()
+	if !j.Enabled(Log_alert) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fmt.Sprintf(format, a...), Log_alert))
+}
+
+func Crit(a ...interface{}) error {
+	j := default_journal()
+	if !j.Enabled(Log_crit) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fmt.Sprintln(a...), Log_crit))
+}
+
+func Critf(format string, a ...interface{}) error {
+	j := default_journal()
+	if !j.Enabled(Log_crit) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fmt.Sprintf(format, a...), Log_crit))
+}
+
+func Err(a ...interface{}) error {
+	j := default_journal()
+	if !j.Enabled(Log_err) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fmt.Sprintln(a...), Log_err))
+}
+
+func Errf(format string, a ...interface{}) error {
+	j := default_journal()
+	if !j.Enabled(Log_err) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fmt.Sprintf(format, a...), Log_err))
+}
+
+func Warning(a ...interface{}) error {
+	j := default_journal()
+	if !j.Enabled(Log_warning) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fmt.Sprintln(a...), Log_warning))
+}
+
+func Warningf(format string, a ...interface{}) error {
+	j := default_journal()
+	if !j.Enabled(Log_warning) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fmt.Sprintf(format, a...), Log_warning))
+}
+
+func Notice(a ...interface{}) error {
+	j := default_journal()
+	if !j.Enabled(Log_notice) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fmt.Sprintln(a...), Log_notice))
+}
+
+func Noticef(format string, a ...interface{}) error {
+	j := default_journal()
+	if !j.Enabled(Log_notice) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fmt.Sprintf(format, a...), Log_notice))
+}
+
+func Info(a ...interface{}) error {
+	j := default_journal()
+	if !j.Enabled(Log_info) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fmt.Sprintln(a...), Log_info))
+}
+
+func Infof(format string, a ...interface{}) error {
+	j := default_journal()
+	if !j.Enabled(Log_info) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fmt.Sprintf(format, a...), Log_info))
+}
+
+func Debug(a ...interface{}) error {
+	j := default_journal()
+	if !j.Enabled(Log_debug) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fmt.Sprintln(a...), Log_debug))
+}
+
+func Debugf(format string, a ...interface{}) error {
+	j := default_journal()
+	if !j.Enabled(Log_debug) {
+		return nil
+	}
+	return j.Send(j.load_defaults(fmt.Sprintf(format, a...), Log_debug))
+}
+
+// Fatal is Journal.Fatal for the Set_default() Journal.
+//
+func Fatal(a ...interface{}) {
+	j := default_journal()
+	j.Send(j.load_defaults(fmt.Sprintln(a...), Log_crit))
+	j.exit()
+}
+
+// Fatalf is Journal.Fatalf for the Set_default() Journal.
+//
+func Fatalf(format string, a ...interface{}) {
+	j := default_journal()
+	j.Send(j.load_defaults(fmt.Sprintf(format, a...), Log_crit))
+	j.exit()
+}
@@ -0,0 +1,42 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build linux && cgo
+// +build linux,cgo
+
+package sd
+
+/*
+#include <stdlib.h>
+#include <systemd/sd-journal.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// Stream_fd returns a file connected to the journal; see man
+// sd_journal_stream_fd. Each line written becomes an entry with
+// SYSLOG_IDENTIFIER identifier and Priority priority. level_prefix allows a
+// line to set its priority with a <N> prefix; i.e. "<3>disk full". Assign
+// the file to exec.Cmd Stdout/Stderr to log a child process. Close the file
+// when done.
+//
+func Stream_fd(identifier string, priority Priority, level_prefix bool) (*os.File, error) {
+	p, err := strconv.Atoi(string(priority))
+	if err != nil {
+		return nil, fmt.Errorf("invalid priority: %q", string(priority))
+	}
+	cs := C.CString(identifier)
+	defer C.free(unsafe.Pointer(cs))
+	fd := C.sd_journal_stream_fd(cs, C.int(p), c_bool(level_prefix))
+	// sd_journal_stream_fd returns a negative errno value on failure
+	if fd < 0 {
+		return nil, fmt.Errorf("sd_journal_stream_fd: %w", syscall.Errno(-fd))
+	}
+	return os.NewFile(uintptr(fd), "journal-stream:"+identifier), nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build linux && !cgo
+// +build linux,!cgo
+
+package sd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// journal_stdout is the journald stream socket; see man
+// systemd-journald.service.
+var journal_stdout = "/run/systemd/journal/stdout"
+
+// Stream_fd returns a file connected to the journal like
+// sd_journal_stream_fd without libsystemd; see stream.go.
+//
+func Stream_fd(identifier string, priority Priority, level_prefix bool) (*os.File, error) {
+	p, err := strconv.Atoi(string(priority))
+	if err != nil {
+		return nil, fmt.Errorf("invalid priority: %q", string(priority))
+	}
+	c, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: journal_stdout, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("sd_journal_stream_fd: %w", err)
+	}
+	defer c.Close()
+	prefix := 0
+	if level_prefix {
+		prefix = 1
+	}
+	// identifier, unit id, priority, level prefix, forward to syslog, kmsg,
+	// console
+	if _, err = fmt.Fprintf(c, "%v\n\n%v\n%v\n0\n0\n0\n", identifier, p, prefix); err != nil {
+		return nil, fmt.Errorf("sd_journal_stream_fd: %w", err)
+	}
+	c.CloseRead()
+	f, err := c.File()
+	if err != nil {
+		return nil, fmt.Errorf("sd_journal_stream_fd: %w", err)
+	}
+	return f, nil
+}
@@ -0,0 +1,17 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build !linux
+// +build !linux
+
+package sd
+
+import (
+	"errors"
+	"os"
+)
+
+// Stream_fd returns an error; there is no journal.
+//
+func Stream_fd(identifier string, priority Priority, level_prefix bool) (*os.File, error) {
+	return nil, errors.New("Stream_fd: the journal requires linux")
+}
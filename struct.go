@@ -0,0 +1,88 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+
+package sd
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Send_struct sends msg with Priority p and the exported fields of the
+// struct, or pointer to struct, v. The field name is the journal tag or the
+// Field_name() of the Go name; i.e. `journal:"REQUEST_ID"`. A `journal:"-"`
+// tag skips the field and `journal:",omitempty"` skips a zero value.
+// Values are sent like Send(); other types are formatted with fmt.Sprint.
+// Embedded structs without a tag add their fields. Empty values are not
+// sent, like the *_m methods.
+//
+func (j *Journal) Send_struct(p Priority, msg string, v interface{}) error {
+	if !j.Enabled(p) {
+		return nil
+	}
+	fields := map[string]interface{}{}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			break
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		struct_fields(fields, rv)
+	case reflect.Ptr:
+	default:
+		return fmt.Errorf("Send_struct: not a struct: %T", v)
+	}
+	return j.Send(j.copy([]map[string]interface{}{fields, j.load_defaults(msg+"\n", p)}...))
+}
+
+// struct_fields adds the exported fields of the struct rv to fields.
+//
+func struct_fields(fields map[string]interface{}, rv reflect.Value) {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("journal")
+		if tag == "-" {
+			continue
+		}
+		name := tag
+		omitempty := false
+		if c := strings.IndexByte(tag, ','); c != -1 {
+			name = tag[:c]
+			omitempty = tag[c+1:] == "omitempty"
+		}
+		fv := rv.Field(i)
+		if f.Anonymous && tag == `` {
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				struct_fields(fields, fv)
+				continue
+			}
+		}
+		if f.PkgPath != `` {
+			// unexported
+			continue
+		}
+		if omitempty && fv.IsZero() {
+			continue
+		}
+		if (fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface) && fv.IsNil() {
+			continue
+		}
+		if name == `` {
+			name = Field_name(f.Name)
+		}
+		value := fv.Interface()
+		if sv, ok := field_value(value); ok {
+			fields[name] = sv
+		} else {
+			fields[name] = fmt.Sprint(value)
+		}
+	}
+}
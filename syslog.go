@@ -0,0 +1,109 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+
+package sd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Facility is a syslog facility. The values are the log/syslog.Priority
+// facilities; log/syslog is not used.
+//
+type Facility int
+
+const (
+	Log_kern Facility = iota << 3
+	Log_user
+	Log_mail
+	Log_daemon
+	Log_auth
+	Log_syslog
+	Log_lpr
+	Log_news
+	Log_uucp
+	Log_cron
+	Log_authpriv
+	Log_ftp
+	_
+	_
+	_
+	_
+	Log_local0
+	Log_local1
+	Log_local2
+	Log_local3
+	Log_local4
+	Log_local5
+	Log_local6
+	Log_local7
+)
+
+// Set_facility sets SYSLOG_FACILITY to the facility of f; i.e. Log_daemon is
+// 3. SYSLOG_PID is set to os.Getpid(). Severity bits of f are ignored. f < 0
+// removes both fields.
+//
+func Set_facility(f Facility) option {
+	return func(o *Journal) option {
+		prev_facility, had_facility := o.default_fields[sd_syslog_facility]
+		prev_pid, had_pid := o.default_fields[sd_syslog_pid]
+		if f < 0 {
+			delete(o.default_fields, sd_syslog_facility)
+			delete(o.default_fields, sd_syslog_pid)
+		} else {
+			o.default_fields[sd_syslog_facility] = strconv.Itoa(int(f&^7) >> 3)
+			o.default_fields[sd_syslog_pid] = strconv.Itoa(os.Getpid())
+		}
+		return func(o *Journal) option {
+			r := Set_facility(-1)(o)
+			if had_facility {
+				o.default_fields[sd_syslog_facility] = prev_facility
+			}
+			if had_pid {
+				o.default_fields[sd_syslog_pid] = prev_pid
+			}
+			return r
+		}
+	}
+}
+
+// Syslog_line formats msg as an RFC 3164 syslog line:
+//
+//	<PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+//
+// PRI is the SYSLOG_FACILITY default field (see Set_facility, default: user)
+// and p. TAG is the SYSLOG_IDENTIFIER default field (see Set_tag) and PID is
+// the SYSLOG_PID default field or os.Getpid(). The newline of msg is
+// removed.
+//
+func (j *Journal) Syslog_line(p Priority, msg string) string {
+	return j.syslog_line(time.Now(), p, msg)
+}
+
+func (j *Journal) syslog_line(t time.Time, p Priority, msg string) string {
+	fields := j.load_defaults(msg, p)
+	facility := 1
+	if s, ok := fields[sd_syslog_facility].(string); ok {
+		if n, err := strconv.Atoi(s); err == nil {
+			facility = n
+		}
+	}
+	severity := p.level()
+	if len(priority_names) <= severity {
+		severity = 6
+	}
+	tag, _ := fields[Sd_tag].(string)
+	pid, ok := fields[sd_syslog_pid].(string)
+	if !ok {
+		pid = strconv.Itoa(os.Getpid())
+	}
+	hostname, _ := os.Hostname()
+	if hostname == `` {
+		hostname = `-`
+	}
+	return fmt.Sprintf("<%v>%v %v %v[%v]: %v", facility<<3|severity, t.Format(time.Stamp), hostname, tag, pid, strings.TrimSuffix(msg, "\n"))
+}
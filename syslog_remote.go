@@ -0,0 +1,153 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package sd
+
+import (
+	"fmt"
+	"log/syslog"
+	"strconv"
+	"sync"
+)
+
+type syslog_remote struct {
+	lock     sync.RWMutex
+	closed   bool
+	refs     int
+	network  string
+	addr     string
+	facility syslog.Priority
+	tag      string
+	queue    chan syslog_message
+}
+
+type syslog_message struct {
+	p   Priority
+	msg string
+}
+
+// Set_syslog_remote also sends MESSAGE to the syslog server at addr with
+// log/syslog; ANSI escapes and the trailing newline are removed. network is tcp, tcp4, tcp6, udp, udp4, udp6, unix, or
+// unixgram. The facility and tag are the SYSLOG_FACILITY and
+// SYSLOG_IDENTIFIER default fields; see Set_facility and Set_tag. The
+// connection is made by a goroutine on the first entry and remade after a
+// failure. Entries are dropped while the server is unavailable or the
+// queue is full; journal sends never block. network "" stops sending.
+// Journals from With() and Clone() use the connection of j until they call
+// Set_syslog_remote; it is closed when no Journal uses it. See Close().
+//
+func (j *Journal) Set_syslog_remote(network, addr string) error {
+	var r *syslog_remote
+	switch network {
+	case ``:
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "unix", "unixgram":
+		fields := j.load_defaults(``, ``)
+		r = &syslog_remote{
+			network:  network,
+			addr:     addr,
+			facility: syslog.LOG_USER,
+			refs:     1,
+			queue:    make(chan syslog_message, 256),
+		}
+		if s, ok := fields[sd_syslog_facility].(string); ok {
+			if n, err := strconv.Atoi(s); err == nil {
+				r.facility = syslog.Priority(n << 3)
+			}
+		}
+		r.tag, _ = fields[Sd_tag].(string)
+		go r.run()
+	default:
+		return fmt.Errorf("Set_syslog_remote: unsupported network: %v", network)
+	}
+	j.lock.Lock()
+	prev := j.remote
+	j.remote = r
+	j.lock.Unlock()
+	prev.release()
+	return nil
+}
+
+// post queues msg without blocking.
+//
+func (r *syslog_remote) post(p Priority, msg string) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.queue <- syslog_message{p: p, msg: msg}:
+	default:
+	}
+}
+
+// acquire adds a Journal using r. r may be nil.
+//
+func (r *syslog_remote) acquire() *syslog_remote {
+	if r == nil {
+		return nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.refs++
+	return r
+}
+
+// release removes a Journal using r and stops the goroutine of r after the
+// last one. r may be nil.
+//
+func (r *syslog_remote) release() {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.refs--
+	if r.refs <= 0 && !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+}
+
+func (r *syslog_remote) run() {
+	var w *syslog.Writer
+	for m := range r.queue {
+		if w == nil {
+			var err error
+			if w, err = syslog.Dial(r.network, r.addr, r.facility|syslog.LOG_INFO, r.tag); err != nil {
+				w = nil
+				continue
+			}
+		}
+		if err := syslog_write(w, m.p, m.msg); err != nil {
+			w.Close()
+			w = nil
+		}
+	}
+	if w != nil {
+		w.Close()
+	}
+}
+
+func syslog_write(w *syslog.Writer, p Priority, msg string) error {
+	switch p {
+	case Log_emerg:
+		return w.Emerg(msg)
+	case Log_alert:
+		return w.Alert(msg)
+	case Log_crit:
+		return w.Crit(msg)
+	case Log_err:
+		return w.Err(msg)
+	case Log_warning:
+		return w.Warning(msg)
+	case Log_notice:
+		return w.Notice(msg)
+	case Log_debug:
+		return w.Debug(msg)
+	default:
+		return w.Info(msg)
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build windows || plan9
+// +build windows plan9
+
+package sd
+
+import "errors"
+
+// log/syslog does not build on windows and plan9.
+type syslog_remote struct{}
+
+func (r *syslog_remote) post(p Priority, msg string) {}
+
+func (r *syslog_remote) acquire() *syslog_remote { return r }
+
+func (r *syslog_remote) release() {}
+
+// Set_syslog_remote returns an error; log/syslog is not supported.
+//
+func (j *Journal) Set_syslog_remote(network, addr string) error {
+	return errors.New("Set_syslog_remote: log/syslog is not supported")
+}
@@ -0,0 +1,68 @@
+// Copyright 2016 aletheia7. All rights reserved. Use of this source code is
+// governed by a BSD-2-Clause license that can be found in the LICENSE file.
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package sd_test
+
+import (
+	. "github.com/aletheia7/sd/v6"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Set_syslog_remote(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer c.Close()
+	j := New_journal_sink(&Memory_sink{})
+	j.Option(Set_tag("app"))
+	if err := j.Set_syslog_remote("bad", ""); err == nil {
+		t.Error("expected error")
+	}
+	if err := j.Set_syslog_remote("udp", c.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+	defer j.Set_syslog_remote("", "")
+	j.Warning("\x1b[31msyslog\x1b[0m remote")
+	b := make([]byte, 1024)
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := c.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b[:n]); !strings.HasPrefix(s, "<12>") || !strings.Contains(s, "app[") || !strings.HasSuffix(s, "]: syslog remote\n") {
+		t.Errorf("%q", s)
+	}
+}
+
+func Test_Set_syslog_remote_child(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer c.Close()
+	j := New_journal_sink(&Memory_sink{})
+	if err := j.Set_syslog_remote("udp", c.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if err := j.With(nil).Set_syslog_remote("", ""); err != nil {
+		t.Fatal(err)
+	}
+	j.Clone().Close()
+	j.Warning("parent remote")
+	b := make([]byte, 1024)
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := c.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.TrimSpace(string(b[:n])); !strings.HasSuffix(s, "parent remote") {
+		t.Errorf("%q", s)
+	}
+}
@@ -5,15 +5,29 @@
 package sd_test
 
 import (
+	"context"
+	"fmt"
 	. "github.com/aletheia7/sd/v6"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func Test_Info(t *testing.T) {
-	j := New_journal()
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
 	if err := j.Info("Info test"); err != nil {
 		t.Error(err)
 	}
+	if e := ms.Entries(); len(e) != 1 || e[0]["MESSAGE"] != "Info test" || e[0]["PRIORITY"] != Log_info {
+		t.Errorf("%q", e)
+	}
 }
 
 func Test_Info_m(t *testing.T) {
@@ -30,3 +44,1426 @@ func Test_Info_m_f(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func Test_Level_writer(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	j.Set_writer_priority(Log_notice)
+	w := Level_writer(j)
+	for _, c := range []struct {
+		in  string
+		p   Priority
+		msg string
+	}{
+		{"<3>disk full\n", Log_err, "disk full"},
+		{"<0>panic\n", Log_emerg, "panic"},
+		{"<7>debug", Log_debug, "debug"},
+		{"no prefix\n", Log_notice, "no prefix"},
+		{"<8>out of range\n", Log_notice, "<8>out of range"},
+		{"<3 missing bracket\n", Log_notice, "<3 missing bracket"},
+		{"<>empty\n", Log_notice, "<>empty"},
+		{"<a>letter\n", Log_notice, "<a>letter"},
+		{" <3>space\n", Log_notice, " <3>space"},
+	} {
+		ms.Reset()
+		n, err := w.Write([]byte(c.in))
+		if err != nil || n != len(c.in) {
+			t.Fatal(n, err)
+		}
+		e := ms.Entries()
+		if len(e) != 1 || e[0]["PRIORITY"] != c.p || e[0]["MESSAGE"] != c.msg {
+			t.Errorf("%q: %q", c.in, e)
+		}
+	}
+	ms.Reset()
+	w.Write([]byte("<4>one\n<6>two\n"))
+	e := ms.Entries()
+	if len(e) != 2 || e[0]["PRIORITY"] != Log_warning || e[0]["MESSAGE"] != "one" || e[1]["PRIORITY"] != Log_info || e[1]["MESSAGE"] != "two" {
+		t.Errorf("%q", e)
+	}
+}
+
+func Test_Send_priority(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	j.Option(Set_min_priority(Log_debug))
+	if err := j.Send_priority(Log_notice, "Send_priority", "test"); err != nil {
+		t.Error(err)
+	}
+	if err := j.Send_priority_f(Log_debug, "Send_priority_f test: %v %q", 1, "a"); err != nil {
+		t.Error(err)
+	}
+	e := ms.Entries()
+	if len(e) != 2 {
+		t.Fatal(len(e))
+	}
+	if e[0]["PRIORITY"] != Log_notice || e[0]["MESSAGE"] != "Send_priority test" {
+		t.Errorf("%q", e[0])
+	}
+	if e[1]["PRIORITY"] != Log_debug || e[1]["MESSAGE"] != `Send_priority_f test: 1 "a"` {
+		t.Errorf("%q", e[1])
+	}
+}
+
+func Test_Priority_String(t *testing.T) {
+	if s := Log_err.String(); s != "err(3)" {
+		t.Error(s)
+	}
+	if s := Log_debug.Name(); s != "debug" {
+		t.Error(s)
+	}
+	if s := Priority("9").Name(); s != "" {
+		t.Error(s)
+	}
+}
+
+func Test_Set_rate_limit(t *testing.T) {
+	j := New(Set_rate_limit(time.Millisecond, 2))
+	for i := 0; i < 5; i++ {
+		if err := j.Info("Set_rate_limit test"); err != nil {
+			t.Error(err)
+		}
+	}
+	if s := j.Stats(); s.Rate_limited != 3 {
+		t.Errorf("%+v", s)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if err := j.Info("Set_rate_limit test"); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_rate_summary_redacted(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Option(Set_rate_limit(time.Millisecond, 1), Set_redactor(func(key string, value []byte) []byte {
+		return []byte(strings.Replace(string(value), "hunter2", "***", -1))
+	}))
+	for i := 0; i < 3; i++ {
+		j.Info("password hunter2")
+	}
+	time.Sleep(2 * time.Millisecond)
+	j.Info("next")
+	e := m.Entries()
+	if len(e) != 3 || e[1]["MESSAGE"] != "message repeated 2 times: [password ***]" {
+		t.Fatalf("%q", e)
+	}
+	for _, f := range e {
+		if strings.Contains(fmt.Sprint(f["MESSAGE"]), "hunter2") {
+			t.Errorf("%q", f)
+		}
+	}
+}
+
+func Test_rate_summary_transformed(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Option(Set_rate_limit(time.Millisecond, 1), Set_message_transform(func(s string) string {
+		return s[:5]
+	}))
+	for i := 0; i < 3; i++ {
+		j.Info("hello", i)
+	}
+	time.Sleep(2 * time.Millisecond)
+	j.Info("next!")
+	e := m.Entries()
+	if len(e) != 3 || e[0]["MESSAGE"] != "hello" || e[1]["MESSAGE"] != "message repeated 2 times: [hello]" {
+		t.Errorf("%q", e)
+	}
+}
+
+func Test_rate_summary_Sync(t *testing.T) {
+	m := &Memory_sink{}
+	var b strings.Builder
+	j := New_journal_sink(m)
+	j.Option(Set_rate_limit(time.Hour, 1), Set_writer(&b))
+	for i := 0; i < 3; i++ {
+		j.Info("burst")
+	}
+	j.Sync()
+	j.Sync()
+	e := m.Entries()
+	if len(e) != 2 || e[1]["MESSAGE"] != "message repeated 2 times: [burst]" {
+		t.Errorf("%q", e)
+	}
+	if b.String() != "burst\nmessage repeated 2 times: [burst]\n" {
+		t.Errorf("%q", b.String())
+	}
+	j.Info("burst")
+	j.Close()
+	if e := m.Entries(); len(e) != 3 || e[2]["MESSAGE"] != "message repeated 1 times: [burst]" {
+		t.Errorf("%q", e)
+	}
+}
+
+func Test_New_async(t *testing.T) {
+	j, close := New_async(4, Set_async_drop(true))
+	for i := 0; i < 10; i++ {
+		if err := j.Info("New_async test", i); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := close(); err != nil {
+		t.Error(err)
+	}
+	if s := j.Stats(); s.Sent+s.Dropped != 10 {
+		t.Errorf("%+v", s)
+	}
+	if err := j.Info("New_async test after close"); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_Memory_sink(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Option(Set_remove_ansi(Remove_journal))
+	if err := j.Info_m(map[string]interface{}{"USER": "bob", "DATA": []byte{0x61, 0x00}}, "\x1b[32mgreen\x1b[0m"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Err_a([]string{"USER=alice"}, "a test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Info_m(map[string]interface{}{"lower": "x"}, "invalid field"); err == nil {
+		t.Error("expected an invalid field error")
+	}
+	e := m.Entries()
+	if len(e) != 2 {
+		t.Fatalf("%v entries", len(e))
+	}
+	if e[0][Sd_message] != "green" || e[0]["USER"] != "bob" || string(e[0]["DATA"].([]byte)) != "a\x00" || e[0]["PRIORITY"] != Log_info {
+		t.Errorf("%q", e[0])
+	}
+	if e[1][Sd_message] != "a test" || e[1]["USER"] != "alice" || e[1]["PRIORITY"] != Log_err {
+		t.Errorf("%q", e[1])
+	}
+	if _, ok := e[1]["GO_FILE"]; !ok {
+		t.Error("missing GO_FILE")
+	}
+}
+
+func Test_binary_fields(t *testing.T) {
+	m := map[string]interface{}{"BINARY_1": []byte{0x61, 0x00, 0x61}, "BINARY_2": []byte{0x62, 0x00, 0x62, 0x62}}
+	if err := New_journal().Info_m(m, "binary fields test"); err != nil {
+		t.Error(err)
+	}
+	ms := &Memory_sink{}
+	if err := New_journal_sink(ms).Info_m(m, "binary fields test"); err != nil {
+		t.Fatal(err)
+	}
+	e := ms.Entries()[0]
+	if string(e["BINARY_1"].([]byte)) != "a\x00a" || string(e["BINARY_2"].([]byte)) != "b\x00bb" {
+		t.Errorf("%q", e)
+	}
+}
+
+func Test_Set_max_field_size(t *testing.T) {
+	j := New_journal_sink(&Memory_sink{})
+	j.Set_add_go_code_fields(false)
+	j.Option(Set_max_field_size(16), Set_auto_identifier(false))
+	err := j.Info_m(map[string]interface{}{"USER_DATA": "more than sixteen bytes"}, "x")
+	if err == nil || !strings.Contains(err.Error(), "USER_DATA") {
+		t.Error(err)
+	}
+}
+
+func Test_Set_use_print(t *testing.T) {
+	j := New_journal()
+	j.Set_use_print(true)
+	if err := j.Info("Set_use_print test"); err != nil {
+		t.Error(err)
+	}
+	if err := j.Info_m(map[string]interface{}{"BINARY": []byte{0x00}}, "Set_use_print binary test"); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_Set_use_print_fields(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Option(Set_auto_identifier(false))
+	j.Set_add_go_code_fields(false)
+	j.Info("print")
+	j.Info_m(map[string]interface{}{"UNIT": "a"}, "print")
+	e := m.Entries()
+	if !Print_only(e[0]) {
+		t.Errorf("%q", e[0])
+	}
+	if Print_only(e[1]) {
+		t.Errorf("%q", e[1])
+	}
+	if Print_only(map[string]interface{}{"MESSAGE": "m", "PRIORITY": "6"}) {
+		t.Error("string PRIORITY")
+	}
+	// binary data is sent with sd_journal_sendv
+	for _, f := range []map[string]interface{}{
+		{"MESSAGE": []byte("m"), "PRIORITY": Log_info},
+		{"MESSAGE": "a\x00b", "PRIORITY": Log_info},
+		{"MESSAGE": "m", "PRIORITY": Log_info, "BINARY": []byte{0x00}},
+		{"MESSAGE": "m", "PRIORITY": Log_info, "LINES": "a\nb"},
+	} {
+		if Print_only(f) {
+			t.Errorf("%q", f)
+		}
+	}
+	if !Print_only(map[string]interface{}{"MESSAGE": "a\nb", "PRIORITY": Log_info}) {
+		t.Error("multi-line MESSAGE")
+	}
+	m.Reset()
+	j.Info_m(map[string]interface{}{"BINARY": []byte{0x00}}, "binary")
+	j.Info("a\x00b")
+	e = m.Entries()
+	if len(e) != 2 || Print_only(e[0]) || Print_only(e[1]) || e[1]["MESSAGE"] != "a\x00b" {
+		t.Errorf("%q", e)
+	}
+}
+
+func Test_Set_code_field_names(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Option(Set_code_field_names(true))
+	j.Info("Set_code_field_names test")
+	e := m.Entries()[0]
+	if _, ok := e["GO_FILE"]; ok {
+		t.Error("unexpected GO_FILE")
+	}
+	if e["CODE_FILE"] == nil || e["CODE_LINE"] == nil || e["CODE_FUNC"] != "github.com/aletheia7/sd/v6_test.Test_Set_code_field_names" {
+		t.Errorf("%q", e)
+	}
+}
+
+func Test_Set_go_field_style(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	_, file, line, _ := runtime.Caller(0)
+	j.Info("combined")
+	j.Option(Set_go_field_style(false))
+	j.Info("separate")
+	e := m.Entries()
+	if !strings.HasSuffix(e[0]["GO_FILE"].(string), fmt.Sprintf("%v:%v", filepath.Base(file), line+1)) || e[0]["GO_LINE"] != nil {
+		t.Errorf("%q", e[0])
+	}
+	if !strings.HasSuffix(e[1]["GO_FILE"].(string), filepath.Base(file)) || e[1]["GO_LINE"] != fmt.Sprint(line+3) {
+		t.Errorf("%q", e[1])
+	}
+}
+
+func Test_Set_add_code_line(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Option(Set_add_code_line(true))
+	_, _, line, _ := runtime.Caller(0)
+	j.Info("code line")
+	e := m.Entries()[0]
+	if e["CODE_LINE"] != fmt.Sprint(line+1) || e["GO_FILE"] == nil {
+		t.Errorf("%q", e)
+	}
+}
+
+func Test_go_file_trimmed(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Info("trimmed")
+	pc, _, _, _ := runtime.Caller(0)
+	j.Log_pc(pc+1, Log_info, "trimmed", nil)
+	for _, e := range m.Entries() {
+		if f := e["GO_FILE"].(string); filepath.IsAbs(f) || !strings.HasPrefix(f, "github.com/aletheia7/sd/v6/z_test.go:") {
+			t.Error(f)
+		}
+	}
+}
+
+func wrapper(j *Journal, s string) error {
+	return j.Info(s)
+}
+
+func Test_Set_stack_skip(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Option(Set_stack_skip(5))
+	_, file, line, _ := runtime.Caller(0)
+	wrapper(j, "Set_stack_skip test")
+	e := m.Entries()[0]
+	if e["GO_FUNC"] != "github.com/aletheia7/sd/v6_test.Test_Set_stack_skip" || !strings.HasSuffix(e["GO_FILE"].(string), fmt.Sprintf("%v:%v", filepath.Base(file), line+1)) {
+		t.Errorf("%q", e)
+	}
+}
+
+func Test_Set_add_stacktrace(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Option(Set_add_stacktrace(Log_err))
+	j.Info("Set_add_stacktrace info")
+	j.Err("Set_add_stacktrace err")
+	e := m.Entries()
+	if _, ok := e[0]["STACKTRACE"]; ok {
+		t.Error("unexpected STACKTRACE")
+	}
+	if s, _ := e[1]["STACKTRACE"].(string); !strings.HasPrefix(s, "github.com/aletheia7/sd/v6_test.Test_Set_add_stacktrace\n") {
+		t.Errorf("%q", s)
+	}
+}
+
+func Test_Set_field_colors(t *testing.T) {
+	var b strings.Builder
+	j := New_journal_sink(&Memory_sink{})
+	j.Option(Set_writer(&b), Set_field(Sd_tag, "app"))
+	Set_field_colors(map[string]string{"PRIORITY": "", Sd_tag: ""})
+	defer Set_field_colors(nil)
+	j.Info("Set_field_colors test")
+	if s := b.String(); s != "Set_field_colors test PRIORITY=info SYSLOG_IDENTIFIER=app\n" {
+		t.Errorf("%q", s)
+	}
+}
+
+func Test_Set_writer_format(t *testing.T) {
+	var b strings.Builder
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	j.Option(Set_writer(&b), Set_writer_format(Format_logfmt), Set_field("REQUEST_ID", "7"), Set_auto_identifier(false))
+	j.Info("hello world")
+	if s := b.String(); s != "MESSAGE=\"hello world\" PRIORITY=info REQUEST_ID=7\n" {
+		t.Errorf("%q", s)
+	}
+	if e := ms.Entries(); len(e) != 1 || e[0]["REQUEST_ID"] != "7" {
+		t.Errorf("%v", e)
+	}
+	b.Reset()
+	j.Option(Set_writer_format(Format_json))
+	j.Info("hello")
+	if s := b.String(); s != `{"MESSAGE":"hello","PRIORITY":"info","REQUEST_ID":"7"}`+"\n" {
+		t.Errorf("%q", s)
+	}
+}
+
+func Test_Export(t *testing.T) {
+	var b strings.Builder
+	err := Export(&b,
+		map[string]interface{}{"MESSAGE": "one", "PRIORITY": Log_info},
+		map[string]interface{}{"MESSAGE": "two\n", "DATA": []byte{0}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "MESSAGE=one\nPRIORITY=6\n\n" +
+		"DATA\n\x01\x00\x00\x00\x00\x00\x00\x00\x00\n" +
+		"MESSAGE\n\x04\x00\x00\x00\x00\x00\x00\x00two\n\n\n"
+	if b.String() != exp {
+		t.Errorf("%q", b.String())
+	}
+	if err := Export(&b, map[string]interface{}{"MESSAGE": struct{}{}}); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func Test_With(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	c := j.With(map[string]interface{}{"REQUEST_ID": "7"})
+	c.Info("child")
+	j.Info("parent")
+	e := ms.Entries()
+	if len(e) != 2 {
+		t.Fatal(len(e))
+	}
+	if e[0]["REQUEST_ID"] != "7" {
+		t.Errorf("%v", e[0])
+	}
+	if _, ok := e[1]["REQUEST_ID"]; ok {
+		t.Errorf("parent changed: %v", e[1])
+	}
+}
+
+func Test_With_a(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	j.Set_default_fields(map[string]interface{}{"APP": "a"})
+	c := j.With_a([]string{"APP=b", "REQUEST_ID=7"})
+	c.Info("child")
+	j.Info("parent")
+	e := ms.Entries()
+	if e[0]["APP"] != "b" || e[0]["REQUEST_ID"] != "7" {
+		t.Errorf("%v", e[0])
+	}
+	if e[1]["APP"] != "a" {
+		t.Errorf("parent changed: %v", e[1])
+	}
+}
+
+func Test_Set_tag(t *testing.T) {
+	Set_default_tag("default_tag")
+	defer Set_default_tag("")
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	j.Info("default")
+	restore := j.Option(Set_tag("app"))
+	j.Info("tag")
+	j.Option(restore)
+	j.Info("restored")
+	e := ms.Entries()
+	for i, exp := range []string{"default_tag", "app", "default_tag"} {
+		if e[i][Sd_tag] != exp {
+			t.Errorf("%v: %v", i, e[i])
+		}
+	}
+}
+
+func Test_Set_auto_identifier(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	j.Info("auto")
+	j.Option(Set_auto_identifier(false))
+	j.Info("none")
+	e := ms.Entries()
+	if e[0][Sd_tag] != filepath.Base(os.Args[0]) {
+		t.Errorf("%v", e[0])
+	}
+	if _, ok := e[1][Sd_tag]; ok {
+		t.Errorf("%v", e[1])
+	}
+}
+
+func Test_Send_catalog(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	Set_message_id("00000000000000000000000000000001")
+	defer Set_message_id("")
+	j.Send_catalog("0123456789abcdef0123456789abcdef", map[string]interface{}{"USER": "a"}, Log_notice, "catalog")
+	j.Send_catalog("", nil, Log_notice, "global")
+	e := ms.Entries()
+	if e[0]["MESSAGE_ID"] != "0123456789abcdef0123456789abcdef" || e[0]["USER"] != "a" {
+		t.Errorf("%v", e[0])
+	}
+	if e[1]["MESSAGE_ID"] != "00000000000000000000000000000001" {
+		t.Errorf("%v", e[1])
+	}
+	if err := j.Send_catalog("0123456789ABCDEF-0123456789abcdef", nil, Log_notice, "bad"); err == nil {
+		t.Error("expected error")
+	}
+	if len(ms.Entries()) != 2 {
+		t.Errorf("%v", ms.Entries())
+	}
+}
+
+func Test_New_message_id(t *testing.T) {
+	a, err := New_message_id()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := New_message_id()
+	if len(a) != 32 || a == b || strings.ToLower(a) != a {
+		t.Errorf("%v %v", a, b)
+	}
+}
+
+func Test_Set_message_id(t *testing.T) {
+	defer Set_message_id("")
+	for _, id := range []string{"0123", "01234567-89ab-cdef-0123-456789abcdef", "0123456789ABCDEF0123456789ABCDEF"} {
+		if Set_message_id(id) == nil {
+			t.Errorf("expected error: %v", id)
+		}
+	}
+	if err := Set_message_id("0123456789abcdef0123456789abcdef"); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_Set_sanitize_fields(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	if err := j.Info_m(map[string]interface{}{"request-id": "7"}, "strict"); err == nil {
+		t.Error("expected error")
+	}
+	j.Option(Set_sanitize_fields(true))
+	if err := j.Info_m(map[string]interface{}{"request-id": "7", "_user": "a"}, "sanitize"); err != nil {
+		t.Fatal(err)
+	}
+	e := ms.Entries()
+	if len(e) != 1 || e[0]["REQUEST_ID"] != "7" || e[0]["USER"] != "a" {
+		t.Errorf("%v", e)
+	}
+}
+
+func Test_Set_skip_invalid_fields(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	j.Option(Set_skip_invalid_fields(true))
+	if err := j.Info_m(map[string]interface{}{"bad": "a", "GOOD": "b"}, "skip"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Send(map[string]interface{}{"MESSAGE": "x", "COUNT": struct{}{}}); err != nil {
+		t.Fatal(err)
+	}
+	e := ms.Entries()
+	if e[0]["GOOD"] != "b" || e[0]["INVALID_FIELDS"] != "bad" {
+		t.Errorf("%v", e[0])
+	}
+	if e[1]["INVALID_FIELDS"] != "COUNT" {
+		t.Errorf("%v", e[1])
+	}
+}
+
+func Test_Valid_field(t *testing.T) {
+	for name, exp := range map[string]bool{"REQUEST_ID": true, "A1": true, "request_id": false, "_PID": false, "": false, "A-B": false} {
+		if Valid_field(name) != exp {
+			t.Errorf("%q: %v", name, !exp)
+		}
+	}
+}
+
+func Test_nil_field(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	if err := j.Send(map[string]interface{}{"MESSAGE": "nil field", "USER": nil}); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Info_m(map[string]interface{}{"USER": nil}, "nil field"); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range ms.Entries() {
+		if _, ok := e["USER"]; ok {
+			t.Errorf("%v", e)
+		}
+	}
+}
+
+func Test_package_lock(t *testing.T) {
+	j := New_journal_sink(&Memory_sink{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			Set_message_id("0123456789abcdef0123456789abcdef")
+			Set_message_id("")
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		j.Info("package lock")
+		j.Set_default_fields(nil)
+	}
+	<-done
+}
+
+func Test_Sync(t *testing.T) {
+	if err := New_journal().Sync(); err != nil {
+		t.Error(err)
+	}
+	j, close := New_async(16)
+	defer close()
+	for i := 0; i < 5; i++ {
+		j.Info("Sync test", i)
+	}
+	if err := j.Sync(); err != nil {
+		t.Error(err)
+	}
+	if s := j.Stats(); s.Sent+s.Failed != 5 {
+		t.Errorf("%+v", s)
+	}
+}
+
+func Test_Close(t *testing.T) {
+	if err := New_journal().Close(); err != nil {
+		t.Error(err)
+	}
+	j, _ := New_async(16)
+	j.Info("Close test")
+	if err := j.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := j.Close(); err != nil {
+		t.Error(err)
+	}
+	if s := j.Stats(); s.Sent+s.Failed != 1 {
+		t.Errorf("%+v", s)
+	}
+}
+
+func Test_Set_add_go_code_fields_min(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	j.Option(Set_add_go_code_fields_min(Log_warning))
+	j.Info("no location")
+	j.Warning("location")
+	e := ms.Entries()
+	if _, ok := e[0]["GO_FILE"]; ok {
+		t.Errorf("%v", e[0])
+	}
+	if _, ok := e[1]["GO_FILE"]; !ok {
+		t.Errorf("%v", e[1])
+	}
+}
+
+func Test_location_used(t *testing.T) {
+	var b strings.Builder
+	j := New_journal_sink(&Memory_sink{})
+	restore := j.Option(Set_default_disable_journal(true))
+	defer j.Option(restore)
+	j.Option(Set_writer(&b))
+	j.Info("no location")
+	j.Option(Set_writer(nil))
+	j.Info("no writer")
+	if s := b.String(); s != "no location\n" {
+		t.Errorf("%q", s)
+	}
+}
+
+func Test_Send_batch(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	_, file, line, _ := runtime.Caller(0)
+	err := j.Send_batch([]map[string]interface{}{
+		{Sd_message: "one", "PRIORITY": Log_info},
+		{Sd_message: "two", "bad": "x"},
+		{Sd_message: "three", "PRIORITY": Log_info},
+	})
+	be, ok := err.(Batch_error)
+	if !ok || len(be) != 3 || be[0] != nil || be[1] == nil || be[2] != nil {
+		t.Fatalf("%v", err)
+	}
+	e := ms.Entries()
+	if len(e) != 2 || e[1][Sd_message] != "three" {
+		t.Fatalf("%v", e)
+	}
+	if !strings.HasSuffix(e[0]["GO_FILE"].(string), fmt.Sprintf("%v:%v", filepath.Base(file), line+1)) {
+		t.Errorf("%v", e[0]["GO_FILE"])
+	}
+}
+
+func Test_Send_batch_code_fields_min(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	j.Option(Set_add_go_code_fields_min(Log_warning))
+	_, file, line, _ := runtime.Caller(0)
+	j.Send_batch([]map[string]interface{}{
+		{Sd_message: "one", "PRIORITY": Log_info},
+		{Sd_message: "two", "PRIORITY": Log_err},
+		{Sd_message: "three", "PRIORITY": Log_debug},
+		{Sd_message: "four", "PRIORITY": Log_warning},
+	})
+	e := ms.Entries()
+	if len(e) != 4 {
+		t.Fatalf("%v", e)
+	}
+	for i, exp := range []bool{false, true, false, true} {
+		f, ok := e[i]["GO_FILE"].(string)
+		if ok != exp || ok && !strings.HasSuffix(f, fmt.Sprintf("%v:%v", filepath.Base(file), line+1)) {
+			t.Errorf("%v %q", i, e[i])
+		}
+	}
+}
+
+func Test_Set_allow_trusted_fields(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	m := map[string]interface{}{"_SOURCE_REALTIME_TIMESTAMP": "1", "OBJECT_PID": "1"}
+	if err := j.Info_m(m, "trusted"); err == nil {
+		t.Error("expected error")
+	}
+	j.Option(Set_allow_trusted_fields(true))
+	if err := j.Info_m(m, "trusted"); err != nil {
+		t.Fatal(err)
+	}
+	if e := ms.Entries(); len(e) != 1 || e[0]["_SOURCE_REALTIME_TIMESTAMP"] != "1" {
+		t.Errorf("%v", e)
+	}
+}
+
+func Test_Set_trim_message_newline(t *testing.T) {
+	var b strings.Builder
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	j.Option(Set_writer(&b))
+	j.Info("trim")
+	j.Option(Set_trim_message_newline(false))
+	j.Info("keep")
+	e := ms.Entries()
+	if e[0][Sd_message] != "trim" || e[1][Sd_message] != "keep\n" {
+		t.Errorf("%q", e)
+	}
+	if s := b.String(); s != "trim\nkeep\n" {
+		t.Errorf("%q", s)
+	}
+}
+
+func Test_Log(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	if err := j.Log(Log_notice, "100% literal %v", map[string]interface{}{"USER": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	e := ms.Entries()[0]
+	if e[Sd_message] != "100% literal %v" || e["USER"] != "a" || e["PRIORITY"] != Log_notice {
+		t.Errorf("%q", e)
+	}
+}
+
+func Test_Send_stream(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	if err := j.Send_stream(Log_info, "STDOUT", strings.NewReader("line 1\nline 2\n")); err != nil {
+		t.Fatal(err)
+	}
+	if e := ms.Entries()[0]; string(e["STDOUT"].([]byte)) != "line 1\nline 2\n" || e[Sd_message] != "STDOUT (14 bytes)" {
+		t.Errorf("%q", e)
+	}
+	j.Option(Set_max_field_size(40), Set_auto_identifier(false))
+	j.Set_add_go_code_fields(false)
+	if err := j.Send_stream(Log_info, "STDOUT", strings.NewReader(strings.Repeat("x", 33))); err != nil {
+		t.Error(err)
+	}
+	if err := j.Send_stream(Log_info, "STDOUT", strings.NewReader(strings.Repeat("x", 34))); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func Test_Set_message_transform(t *testing.T) {
+	var b strings.Builder
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	j.Option(Set_writer(&b), Set_message_transform(func(s string) string {
+		return strings.Replace(s, "secret", "******", -1)
+	}))
+	j.Info("token secret")
+	if e := ms.Entries()[0]; e[Sd_message] != "token ******" {
+		t.Errorf("%q", e)
+	}
+	if s := b.String(); s != "token ******\n" {
+		t.Errorf("%q", s)
+	}
+}
+
+func Test_Set_redactor(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	j.Option(Set_redactor(func(key string, value []byte) []byte {
+		if key == "TOKEN" {
+			return []byte("******")
+		}
+		return value
+	}))
+	j.Info_m(map[string]interface{}{"TOKEN": "abc", "DATA": []byte("TOKEN")}, "redact")
+	e := ms.Entries()[0]
+	if e["TOKEN"] != "******" || string(e["DATA"].([]byte)) != "TOKEN" || e[Sd_message] != "redact" {
+		t.Errorf("%q", e)
+	}
+}
+
+func Test_Set_sample(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	j.Option(Set_sample(Log_debug, 3))
+	for i := 0; i < 7; i++ {
+		j.Debug("sample", i)
+	}
+	j.Info("not sampled")
+	e := ms.Entries()
+	if len(e) != 4 {
+		t.Fatalf("%v", len(e))
+	}
+	for i, exp := range []string{"sample 0", "sample 3", "sample 6"} {
+		if e[i][Sd_message] != exp || e[i]["SAMPLED"] != "3" {
+			t.Errorf("%q", e[i])
+		}
+	}
+	if _, ok := e[3]["SAMPLED"]; ok {
+		t.Errorf("%q", e[3])
+	}
+}
+
+func Test_Send_n(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	j.Option(Set_skip_invalid_fields(true))
+	_, file, line, _ := runtime.Caller(0)
+	n, err := j.Send_n(map[string]interface{}{Sd_message: "Send_n", "USER": nil, "bad": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// MESSAGE, INVALID_FIELDS, GO_FILE, GO_FUNC
+	if n != 4 {
+		t.Errorf("%v", n)
+	}
+	if e := ms.Entries()[0]; !strings.HasSuffix(e["GO_FILE"].(string), fmt.Sprintf("%v:%v", filepath.Base(file), line+1)) {
+		t.Errorf("%q", e)
+	}
+}
+
+func Test_Set_writer_min(t *testing.T) {
+	var b strings.Builder
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	j.Option(Set_writer(&b), Set_writer_min(Log_info))
+	j.Debug("debug")
+	j.Info("info")
+	j.Debug("debug")
+	if s := b.String(); s != "info\n" {
+		t.Errorf("%q", s)
+	}
+	if len(ms.Entries()) != 3 {
+		t.Error(len(ms.Entries()))
+	}
+}
+
+func Test_Set_writer_header(t *testing.T) {
+	var b strings.Builder
+	j := New_journal_sink(&Memory_sink{})
+	j.Option(Set_writer(&b), Set_writer_header("2006"))
+	j.Infof("no newline %v", 1)
+	j.Info("newline")
+	year := time.Now().Format("2006")
+	if s := b.String(); s != year+" info no newline 1\n"+year+" info newline\n" {
+		t.Errorf("%q", s)
+	}
+}
+
+func Test_large_entry(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	if err := j.Info_m(map[string]interface{}{"DATA": strings.Repeat("x", 4<<20)}, "large entry test"); err != nil {
+		t.Error(err)
+	}
+	if e := ms.Entries(); len(e) != 1 || len(e[0]["DATA"].(string)) != 4<<20 {
+		t.Error("DATA")
+	}
+}
+
+// Export and the native protocol sender share the field framing.
+func Test_field_framing(t *testing.T) {
+	for _, c := range []struct {
+		v   interface{}
+		exp string
+	}{
+		{"text", "F=text\n"},
+		{"", "F=\n"},
+		{"a\nb", "F\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n"},
+		{"a\x00b", "F\n\x03\x00\x00\x00\x00\x00\x00\x00a\x00b\n"},
+		{"goroutine 1\n\tmain.go:1\n", "F\n\x17\x00\x00\x00\x00\x00\x00\x00goroutine 1\n\tmain.go:1\n\n"},
+		{[]byte{}, "F\n\x00\x00\x00\x00\x00\x00\x00\x00\n"},
+	} {
+		var b strings.Builder
+		if err := Export(&b, map[string]interface{}{"F": c.v}); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != c.exp+"\n" {
+			t.Errorf("%q: %q", c.v, b.String())
+		}
+	}
+}
+
+type count_stringer int
+
+func (c *count_stringer) String() string {
+	*c++
+	return "counted"
+}
+
+func Test_Set_min_priority(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Option(Set_min_priority(Log_info))
+	if j.Enabled(Log_debug) || !j.Enabled(Log_info) || !j.Enabled(Log_err) {
+		t.Error("Enabled")
+	}
+	var c count_stringer
+	j.Debug(&c)
+	j.Debugf("%v", &c)
+	j.Send(map[string]interface{}{Sd_message: "debug", "PRIORITY": Log_debug})
+	j.Send_priority(Log_debug, &c)
+	j.Send_priority_f(Log_debug, "%v", &c)
+	j.Send_catalog("", nil, Log_debug, &c)
+	j.Log(Log_debug, "debug", nil)
+	r := strings.NewReader("debug")
+	j.Send_stream(Log_debug, "STDOUT", r)
+	if r.Len() != len("debug") {
+		t.Error("Send_stream read r")
+	}
+	j.Info(&c)
+	if c != 1 || len(m.Entries()) != 1 || m.Entries()[0]["PRIORITY"] != Log_info {
+		t.Errorf("%v %q", c, m.Entries())
+	}
+}
+
+func Test_Debug_fn(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Option(Set_min_priority(Log_info))
+	called := 0
+	fn := func() string {
+		called++
+		return "lazy"
+	}
+	j.Debug_fn(fn)
+	j.Info_fn(fn)
+	if called != 1 || len(m.Entries()) != 1 || m.Entries()[0]["MESSAGE"] != "lazy" {
+		t.Errorf("%v %q", called, m.Entries())
+	}
+}
+
+func Test_Err_err(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	_, err := os.Open("/nonexistent/sd")
+	err = fmt.Errorf("config: %w", err)
+	j.Err_err(err, map[string]interface{}{"UNIT": "x"})
+	j.Err_err(nil, nil)
+	e := m.Entries()
+	if len(e) != 1 {
+		t.Fatalf("%q", e)
+	}
+	if e[0]["MESSAGE"] != err.Error() || e[0]["ERRNO"] != "2" || e[0]["UNIT"] != "x" || e[0]["PRIORITY"] != Log_err {
+		t.Errorf("%q", e[0])
+	}
+	if exp := err.Error() + "\nopen /nonexistent/sd: no such file or directory\nno such file or directory"; e[0]["ERROR_CHAIN"] != exp {
+		t.Errorf("%q", e[0]["ERROR_CHAIN"])
+	}
+}
+
+func Test_Register_error_field(t *testing.T) {
+	Register_error_field(context.DeadlineExceeded, "TIMEOUT", "0")
+	Register_error_field(context.DeadlineExceeded, "TIMEOUT", "1")
+	Register_error_field(context.Canceled, "CANCELED", "1")
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Err_err(fmt.Errorf("fetch: %w", context.DeadlineExceeded), nil)
+	e := m.Entries()[0]
+	if e["TIMEOUT"] != "1" || e["CANCELED"] != nil {
+		t.Errorf("%q", e)
+	}
+}
+
+func Test_Fatal(t *testing.T) {
+	if os.Getenv("SD_TEST_FATAL") == "1" {
+		j := New_journal_sink(&Memory_sink{})
+		j.Option(Set_writer(os.Stdout), Set_fatal_exit_code(3))
+		j.Fatalf("fatal %v", 1)
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^Test_Fatal$")
+	cmd.Env = append(os.Environ(), "SD_TEST_FATAL=1")
+	out, err := cmd.Output()
+	if e, ok := err.(*exec.ExitError); !ok || e.ExitCode() != 3 || !strings.Contains(string(out), "fatal 1\n") {
+		t.Errorf("%v %q", err, out)
+	}
+}
+
+func Test_Panic(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	defer func() {
+		if r := recover(); r != "panic 1" {
+			t.Errorf("%q", r)
+		}
+		if e := m.Entries(); len(e) != 1 || e[0]["MESSAGE"] != "panic 1" || e[0]["PRIORITY"] != Log_crit {
+			t.Errorf("%q", e)
+		}
+	}()
+	j.Panicf("panic %v", 1)
+}
+
+func Test_New_std_logger(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	l := New_std_logger(j, Log_warning, "lib: ", log.LstdFlags)
+	_, file, line, _ := runtime.Caller(0)
+	l.Println("std logger")
+	e := m.Entries()[0]
+	if e["MESSAGE"] != "lib: std logger" || e["PRIORITY"] != Log_warning || !strings.HasSuffix(e["GO_FILE"].(string), fmt.Sprintf("%v:%v", filepath.Base(file), line+1)) {
+		t.Errorf("%q", e)
+	}
+}
+
+func Test_Write_log_location(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Option(Set_go_field_style(false))
+	l := log.New(j, "", log.Lshortfile)
+	_, _, line, _ := runtime.Caller(0)
+	l.Println("log location")
+	j.Write([]byte("no location\n"))
+	e := m.Entries()
+	if e[0]["MESSAGE"] != "log location" || e[0]["GO_FILE"] != "z_test.go" || e[0]["GO_LINE"] != fmt.Sprint(line+1) || e[0]["GO_FUNC"] != nil {
+		t.Errorf("%q", e[0])
+	}
+	if e[1]["MESSAGE"] != "no location" || e[1]["GO_FUNC"] == nil {
+		t.Errorf("%q", e[1])
+	}
+}
+
+func Test_Set_writer(t *testing.T) {
+	var a, b strings.Builder
+	j1 := New_journal_sink(&Memory_sink{})
+	j1.Option(Set_writer(&a))
+	j2 := New_journal_sink(&Memory_sink{})
+	j2.Option(Set_writer(&b))
+	j1.Info("one")
+	j2.Info("two")
+	if a.String() != "one\n" || b.String() != "two\n" {
+		t.Errorf("%q %q", a.String(), b.String())
+	}
+}
+
+func Test_New_ring_writer(t *testing.T) {
+	w, recent := New_ring_writer(2)
+	j := New_journal_sink(&Memory_sink{})
+	j.Option(Set_writer(w))
+	j.Info("one")
+	if r := recent(); len(r) != 1 || r[0] != "one" {
+		t.Errorf("%q", r)
+	}
+	j.Info("two")
+	j.Err("three")
+	w.Write([]byte("four"))
+	if r := recent(); len(r) != 2 || r[0] != "two" || !strings.HasSuffix(r[1], " three") {
+		t.Errorf("%q", r)
+	}
+}
+
+func Test_Last_sent(t *testing.T) {
+	j := New_journal_sink(&Memory_sink{})
+	if !j.Last_sent().IsZero() {
+		t.Error(j.Last_sent())
+	}
+	start := time.Now()
+	j.Info("last sent")
+	if l := j.Last_sent(); l.Before(start) || time.Since(l) < 0 {
+		t.Error(l)
+	}
+}
+
+func Test_Set_field_order(t *testing.T) {
+	Set_field_order([]string{"REQUEST_ID", "PRIORITY"})
+	defer Set_field_order(nil)
+	s := Format_logfmt(map[string]interface{}{"MESSAGE": "m", "PRIORITY": Log_info, "REQUEST_ID": "7", "B": "b", "A": "a"})
+	if s != "REQUEST_ID=7 PRIORITY=info A=a B=b MESSAGE=m" {
+		t.Error(s)
+	}
+}
+
+func Test_Export_order(t *testing.T) {
+	fields := map[string]interface{}{}
+	for _, k := range []string{"D", "B", "E", "A", "C", "MESSAGE"} {
+		fields[k] = k
+	}
+	var first string
+	for i := 0; i < 10; i++ {
+		var b strings.Builder
+		Export(&b, fields)
+		if i == 0 {
+			first = b.String()
+		} else if b.String() != first {
+			t.Fatalf("%q %q", first, b.String())
+		}
+	}
+	if first != "A=A\nB=B\nC=C\nD=D\nE=E\nMESSAGE=MESSAGE\n\n" {
+		t.Errorf("%q", first)
+	}
+}
+
+func Test_Clone(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Set_default_fields(map[string]interface{}{"UNIT": "a", "DATA": []byte("x")})
+	c := j.Clone()
+	c.Option(Set_priority(Log_err), Set_auto_identifier(false))
+	c.Set_default_fields(map[string]interface{}{"UNIT": "b"})
+	j.Write([]byte("original\n"))
+	c.Write([]byte("clone\n"))
+	e := m.Entries()
+	if e[0]["UNIT"] != "a" || e[0]["PRIORITY"] != Log_info || e[0]["SYSLOG_IDENTIFIER"] == nil {
+		t.Errorf("%q", e[0])
+	}
+	if e[1]["UNIT"] != "b" || e[1]["PRIORITY"] != Log_err || e[1]["SYSLOG_IDENTIFIER"] != nil {
+		t.Errorf("%q", e[1])
+	}
+}
+
+func Test_Set_fields(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Option(Set_field("UNIT", "a"))
+	restore := j.Option(Set_fields(map[string]interface{}{"UNIT": "b", "REQUEST_ID": "7"}))
+	j.Info("set")
+	j.Option(restore)
+	j.Info("restored")
+	e := m.Entries()
+	if e[0]["UNIT"] != "b" || e[0]["REQUEST_ID"] != "7" {
+		t.Errorf("%q", e[0])
+	}
+	if _, ok := e[1]["REQUEST_ID"]; ok || e[1]["UNIT"] != "a" {
+		t.Errorf("%q", e[1])
+	}
+}
+
+func Test_Option_restore(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	prev := j.Option(Set_priority(Log_err), Set_field("UNIT", "a"), Set_priority(Log_warning))
+	j.Write([]byte("set\n"))
+	j.Option(prev)
+	j.Write([]byte("restored\n"))
+	e := m.Entries()
+	if e[0]["PRIORITY"] != Log_warning || e[0]["UNIT"] != "a" {
+		t.Errorf("%q", e[0])
+	}
+	if _, ok := e[1]["UNIT"]; ok || e[1]["PRIORITY"] != Log_info {
+		t.Errorf("%q", e[1])
+	}
+}
+
+func Test_duration_field(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Send(map[string]interface{}{"MESSAGE": "duration", "LATENCY": 1500 * time.Microsecond})
+	j.Info_m(map[string]interface{}{"LATENCY": 2 * time.Second}, "duration")
+	e := m.Entries()
+	if e[0]["LATENCY"] != "1500" || e[1]["LATENCY"] != "2000000" {
+		t.Errorf("%q", e)
+	}
+	var b strings.Builder
+	if err := Export(&b, map[string]interface{}{"LATENCY": time.Millisecond}); err != nil || b.String() != "LATENCY=1000\n\n" {
+		t.Errorf("%v %q", err, b.String())
+	}
+}
+
+type user_id int
+
+func (u user_id) String() string {
+	return fmt.Sprintf("user-%d", int(u))
+}
+
+func Test_Stringer_field(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	err := j.Send(map[string]interface{}{"MESSAGE": "stringer", "USER": user_id(7), "ERROR": os.ErrNotExist})
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Info_m(map[string]interface{}{"USER": user_id(8)}, "stringer")
+	e := m.Entries()
+	if e[0]["USER"] != "user-7" || e[0]["ERROR"] != "file does not exist" || e[1]["USER"] != "user-8" {
+		t.Errorf("%q", e)
+	}
+}
+
+type my_err struct{}
+
+func (e *my_err) Error() string {
+	return "my_err"
+}
+
+func Test_nil_pointer_field(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	var err error = (*my_err)(nil)
+	var u *user_id
+	if err := j.Send(map[string]interface{}{"MESSAGE": "nil", "ERROR": err, "USER": u}); err != nil {
+		t.Fatal(err)
+	}
+	j.Info_m(map[string]interface{}{"ERROR": err}, "nil")
+	for _, e := range m.Entries() {
+		if _, ok := e["ERROR"]; ok {
+			t.Errorf("%q", e)
+		}
+		if _, ok := e["USER"]; ok {
+			t.Errorf("%q", e)
+		}
+	}
+	var b strings.Builder
+	if err := Export(&b, map[string]interface{}{"MESSAGE": "nil", "ERROR": err}); err != nil || b.String() != "MESSAGE=nil\n\n" {
+		t.Errorf("%v %q", err, b.String())
+	}
+}
+
+func Test_Set_field_typed(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	b := []byte("data")
+	j.Option(Set_field_int("WORKER", 3), Set_field_bytes("DATA", b), Set_field_time("STARTED", time.Unix(2, 5000)))
+	b[0] = 'x'
+	j.Info("typed")
+	e := m.Entries()[0]
+	if e["WORKER"] != "3" || string(e["DATA"].([]byte)) != "data" || e["STARTED"] != "2000005" {
+		t.Errorf("%q", e)
+	}
+}
+
+func Test_Set_field_numeric(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Option(Set_field("X", 5), Set_field("Y", 1.5), Set_field("Z", true))
+	if err := j.Info("numeric"); err != nil {
+		t.Fatal(err)
+	}
+	e := m.Entries()
+	if len(e) != 1 || e[0]["X"] != "5" || e[0]["Y"] != "1.5" || e[0]["Z"] != "true" {
+		t.Errorf("%q", e)
+	}
+	if err := j.Send(map[string]interface{}{"MESSAGE": "n", "N": uint8(7)}); err != nil || m.Entries()[1]["N"] != "7" {
+		t.Error(err, m.Entries())
+	}
+	if _, err := Set_field_checked("X", struct{}{}); err == nil {
+		t.Error("no error")
+	}
+	if _, err := Set_field_checked("x-y", "v"); err == nil {
+		t.Error("no error")
+	}
+	opt, err := Set_field_checked("W", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Option(opt, Set_field("X", struct{}{}))
+	if err := j.Info("unsupported"); err != nil {
+		t.Fatal(err)
+	}
+	if e := m.Entries()[2]; e["X"] != "5" || e["W"] != "7" {
+		t.Errorf("%q", e)
+	}
+}
+
+func Test_Default(t *testing.T) {
+	var b strings.Builder
+	j := Default()
+	defer j.Option(j.Option(Set_default_disable_journal(true), Set_writer(&b)))
+	if err := j.Info("Default test"); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(b.String(), "Default test") {
+		t.Errorf("%q", b.String())
+	}
+}
+
+func Test_Set_default(t *testing.T) {
+	m := &Memory_sink{}
+	Set_default(New_journal_sink(m))
+	defer Set_default(nil)
+	_, file, line, _ := runtime.Caller(0)
+	Info("package level")
+	Warningf("package %v", "level")
+	e := m.Entries()
+	if len(e) != 2 || e[0]["MESSAGE"] != "package level" || e[1]["PRIORITY"] != Log_warning || !strings.HasSuffix(e[0]["GO_FILE"].(string), fmt.Sprintf("%v:%v", filepath.Base(file), line+1)) {
+		t.Errorf("%q", e)
+	}
+}
+
+func Test_SYSTEMD_LOG_LEVEL(t *testing.T) {
+	defer os.Unsetenv("SYSTEMD_LOG_LEVEL")
+	os.Setenv("SYSTEMD_LOG_LEVEL", "info")
+	j := New_journal_sink(&Memory_sink{})
+	if j.Enabled(Log_debug) || !j.Enabled(Log_info) {
+		t.Error("info")
+	}
+	j.Option(Set_min_priority(Log_debug))
+	if !j.Enabled(Log_debug) {
+		t.Error("Set_min_priority")
+	}
+	os.Setenv("SYSTEMD_LOG_LEVEL", "7")
+	if !New_journal_sink(&Memory_sink{}).Enabled(Log_debug) {
+		t.Error("7")
+	}
+	os.Setenv("SYSTEMD_LOG_LEVEL", "bogus")
+	if !New_journal_sink(&Memory_sink{}).Enabled(Log_debug) {
+		t.Error("bogus")
+	}
+}
+
+func Test_field_count_location(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Option(Set_auto_identifier(false))
+	fields := map[string]interface{}{}
+	for i := 0; len(fields) < 1024; i++ {
+		fields[fmt.Sprintf("F%v", i)] = "v"
+	}
+	err := j.Send(fields)
+	if err == nil || !strings.HasSuffix(err.Error(), ": 1024 given and 2 location fields") {
+		t.Errorf("%v", err)
+	}
+}
+
+func Test_Set_split_entries(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	j.Option(Set_split_entries(true))
+	fields := map[string]interface{}{"MESSAGE": "split"}
+	for i := 0; i < 2000; i++ {
+		fields[fmt.Sprintf("F%v", i)] = "v"
+	}
+	if err := j.Send(fields); err != nil {
+		t.Fatal(err)
+	}
+	e := m.Entries()
+	seen := map[string]bool{}
+	for i, p := range e {
+		if p["MESSAGE"] != "split" || p["ENTRY_GROUP"] != e[0]["ENTRY_GROUP"] || p["ENTRY_PART"] != fmt.Sprint(i+1) || p["GO_FUNC"] == nil {
+			t.Fatalf("%v %q", i, p["ENTRY_PART"])
+		}
+		for k := range p {
+			if strings.HasPrefix(k, "F") {
+				seen[k] = true
+			}
+		}
+	}
+	if len(e) < 2 || len(seen) != 2000 {
+		t.Errorf("%v %v", len(e), len(seen))
+	}
+}
+
+type send_struct_base struct {
+	Service string
+}
+
+type send_struct_test struct {
+	send_struct_base
+	ID      int           `journal:"REQUEST_ID"`
+	User    string        `journal:",omitempty"`
+	Retries int           `journal:",omitempty"`
+	Latency time.Duration `journal:"LATENCY"`
+	Secret  string        `journal:"-"`
+	private string
+}
+
+func Test_Send_struct(t *testing.T) {
+	m := &Memory_sink{}
+	j := New_journal_sink(m)
+	v := send_struct_test{send_struct_base{"api"}, 7, "", 0, time.Millisecond, "s", "p"}
+	if err := j.Send_struct(Log_info, "request", &v); err != nil {
+		t.Fatal(err)
+	}
+	e := m.Entries()[0]
+	if e["MESSAGE"] != "request" || e["SERVICE"] != "api" || e["REQUEST_ID"] != "7" || e["LATENCY"] != "1000" {
+		t.Errorf("%q", e)
+	}
+	for _, k := range []string{"USER", "RETRIES", "SECRET", "PRIVATE"} {
+		if _, ok := e[k]; ok {
+			t.Error(k)
+		}
+	}
+	if err := j.Send_struct(Log_info, "not a struct", 5); err == nil {
+		t.Error("int")
+	}
+}
+
+func Test_Set_facility(t *testing.T) {
+	ms := &Memory_sink{}
+	j := New_journal_sink(ms)
+	restore := j.Option(Set_facility(Log_daemon))
+	j.Info("facility")
+	j.Option(restore)
+	j.Info("restored")
+	e := ms.Entries()
+	if e[0]["SYSLOG_FACILITY"] != "3" || e[0]["SYSLOG_PID"] != strconv.Itoa(os.Getpid()) {
+		t.Errorf("%v", e[0])
+	}
+	if _, ok := e[1]["SYSLOG_FACILITY"]; ok {
+		t.Errorf("%v", e[1])
+	}
+}
+
+func Test_Syslog_line(t *testing.T) {
+	j := New_journal_sink(&Memory_sink{})
+	j.Option(Set_facility(Log_daemon), Set_tag("app"))
+	s := j.Syslog_line(Log_err, "hello\n")
+	if !strings.HasPrefix(s, "<27>") || !strings.HasSuffix(s, fmt.Sprintf(" app[%v]: hello", os.Getpid())) {
+		t.Errorf("%q", s)
+	}
+}